@@ -0,0 +1,346 @@
+package approve
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/gookit/slog"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+)
+
+const (
+	approveActorName = "approve"
+
+	// statusMarker identifies the status comment maintained by the approve actor
+	statusMarker = "<!-- actbot:approve-status -->"
+
+	// botType the account type of GitHub Apps, including the GitHub Actions app behind GITHUB_TOKEN
+	botType = "Bot"
+
+	openedAction      = "opened"
+	reopenedAction    = "reopened"
+	synchronizeAction = "synchronize"
+
+	approveCommand = "approve"
+)
+
+type actor struct {
+	ghClient *github.Client
+	logger   *slog.Logger
+	cfg      *config.Config
+
+	event   github.IssueCommentEvent
+	prEvent *github.PullRequestEvent
+}
+
+func NewApproveActor(ghClient *github.Client, logger *slog.Logger, cfg *config.Config) actors.Actor {
+	return &actor{
+		ghClient: ghClient,
+		logger:   logger,
+		cfg:      cfg,
+	}
+}
+
+func (a *actor) Handler(ctx context.Context) error {
+	if a.prEvent != nil {
+		var (
+			pr       = a.prEvent.GetPullRequest()
+			fullName = a.prEvent.GetRepo().GetFullName()
+		)
+		a.logger.Infof("actor %s started processing events, pr number: #%d", a.Name(), pr.GetNumber())
+
+		return a.refresh(ctx, fullName, pr, nil)
+	}
+
+	var (
+		issue    = a.event.GetIssue()
+		comment  = a.event.GetComment()
+		fullName = a.event.GetRepo().GetFullName()
+	)
+	a.logger.Infof("actor %s started processing events, pr number: #%d", a.Name(), issue.GetNumber())
+
+	pr, err := actors.GetPRFromIssue(ctx, a.ghClient, fullName, issue)
+	if err != nil {
+		return err
+	}
+
+	if err := a.refresh(ctx, fullName, pr, comment); err != nil {
+		return err
+	}
+
+	return actors.AddReaction(ctx, a.ghClient, a.cfg.Reactions.Commend, fullName, comment.GetID())
+}
+
+// refresh recomputes the approval state of the pull request, and updates the approved label and the status comment.
+// The comment is the approve instruction which triggered the refresh, it is nil when the pull request itself changed.
+func (a *actor) refresh(ctx context.Context, fullName string, pr *github.PullRequest, comment *github.IssueComment) error {
+	number := pr.GetNumber()
+
+	files, err := a.listFiles(ctx, fullName, number)
+	if err != nil {
+		return err
+	}
+
+	ownersMap, err := a.loadOwners(ctx, fullName, pr.GetBase().GetRef(), files)
+	if err != nil {
+		return err
+	}
+
+	// avoid posting a status comment on pull requests nobody owns
+	if comment == nil && len(ownersMap) == 0 {
+		a.logger.Infof("pr #%d is not covered by any OWNERS file", number)
+		return nil
+	}
+
+	comments, err := a.listComments(ctx, fullName, number)
+	if err != nil {
+		return err
+	}
+	// users who are not approvers of any OWNERS file can write /approve, but they neither approve nor appear in the status
+	approved := approvals(comments, comment).Intersection(approvers(ownersMap))
+
+	pending := pendingOwners(files, ownersMap, approved)
+	isApproved := len(pending) == 0 && approved.Len() > 0
+	a.logger.Infof("pr #%d approved by [%s], %d OWNERS files pending", number, strings.Join(sets.List(approved), ","), len(pending))
+
+	if isApproved {
+		if err := actors.AddLabelToIssue(ctx, a.ghClient, fullName, number, a.cfg.Labels.Approved); err != nil {
+			return err
+		}
+		a.logger.Infof("add '%s' label to pr #%d", a.cfg.Labels.Approved, number)
+	} else {
+		if err := actors.RemoveLabelToIssue(ctx, a.ghClient, fullName, number, a.cfg.Labels.Approved); err != nil {
+			return err
+		}
+		a.logger.Infof("remove '%s' label from pr #%d", a.cfg.Labels.Approved, number)
+	}
+
+	return a.upsertStatusComment(ctx, fullName, number, comments, statusBody(isApproved, approved, pending))
+}
+
+func (a *actor) Capture(ctx context.Context, event actors.GenericEvent) bool {
+	genericEvent := event.Event
+	if prEvent, ok := genericEvent.(github.PullRequestEvent); ok {
+		return a.capturePullRequest(prEvent)
+	}
+
+	commentEvent, ok := genericEvent.(github.IssueCommentEvent)
+	if !ok {
+		a.logger.Error("cannot extract event to github.IssueCommentEvent, please check event type")
+		return false
+	}
+
+	if !commentEvent.Issue.IsPullRequest() || len(commentEvent.Comment.GetBody()) == 0 {
+		return false
+	}
+	if commentEvent.Issue.GetClosedBy() != nil || !commentEvent.Issue.GetClosedAt().IsZero() {
+		return false
+	}
+
+	if _, ok := commands.MatchInstruction(event.Commands, approveCommand); !ok {
+		return false
+	}
+	a.event = commentEvent
+
+	return true
+}
+
+// capturePullRequest refreshes the approval state whenever the changed files may have changed
+func (a *actor) capturePullRequest(prEvent github.PullRequestEvent) bool {
+	switch prEvent.GetAction() {
+	case openedAction, reopenedAction, synchronizeAction:
+		a.prEvent = &prEvent
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *actor) Name() string {
+	return approveActorName
+}
+
+func (a *actor) Commands() []string {
+	return []string{approveCommand}
+}
+
+func (a *actor) listFiles(ctx context.Context, fullName string, number int) ([]string, error) {
+	owner, repoName := actors.GetOwnerRepo(fullName)
+
+	commitFiles, err := actors.ListAll(func(opts github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+		return a.ghClient.PullRequests.ListFiles(ctx, owner, repoName, number, &opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range commitFiles {
+		files = append(files, file.GetFilename())
+		// a renamed file also affects the directory it was moved from
+		if len(file.GetPreviousFilename()) != 0 {
+			files = append(files, file.GetPreviousFilename())
+		}
+	}
+
+	return files, nil
+}
+
+func (a *actor) listComments(ctx context.Context, fullName string, number int) ([]*github.IssueComment, error) {
+	owner, repoName := actors.GetOwnerRepo(fullName)
+
+	return actors.ListAll(func(opts github.ListOptions) ([]*github.IssueComment, *github.Response, error) {
+		return a.ghClient.Issues.ListComments(ctx, owner, repoName, number, &github.IssueListCommentsOptions{ListOptions: opts})
+	})
+}
+
+// loadOwners reads the OWNERS files of every directory touched by the files from the base branch.
+// The result is keyed by directory and only contains directories which have an OWNERS file.
+func (a *actor) loadOwners(ctx context.Context, fullName, ref string, files []string) (map[string]*owners, error) {
+	var (
+		visited   = sets.New[string]()
+		ownersMap = make(map[string]*owners)
+	)
+	for _, file := range files {
+		for _, dir := range ancestorDirs(file) {
+			if visited.Has(dir) {
+				continue
+			}
+			visited.Insert(dir)
+
+			content, found, err := actors.GetFileContent(ctx, a.ghClient, fullName, ownersPath(dir), ref)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read '%s': %w", ownersPath(dir), err)
+			}
+			if !found {
+				continue
+			}
+
+			o, err := parseOwners(content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse '%s': %w", ownersPath(dir), err)
+			}
+			ownersMap[dir] = o
+		}
+	}
+
+	return ownersMap, nil
+}
+
+// upsertStatusComment edits the status comment previously created by the actor, or creates it if absent.
+// Anyone can write the marker, so only a comment written by the bot itself is edited.
+func (a *actor) upsertStatusComment(ctx context.Context, fullName string, number int, comments []*github.IssueComment, body string) error {
+	owner, repoName := actors.GetOwnerRepo(fullName)
+	login, err := actors.GetAuthenticatedLogin(ctx, a.ghClient)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range comments {
+		if !strings.Contains(c.GetBody(), statusMarker) || !writtenBy(c.GetUser(), login) {
+			continue
+		}
+
+		_, _, err := a.ghClient.Issues.EditComment(
+			ctx,
+			owner,
+			repoName,
+			c.GetID(),
+			&github.IssueComment{Body: &body},
+		)
+		return err
+	}
+
+	return actors.AddComment(ctx, a.ghClient, body, fullName, number)
+}
+
+// writtenBy reports whether the user is the account of the login. When the login cannot be read
+// with an installation token, the user must be a bot account since users cannot comment as one.
+func writtenBy(user *github.User, login string) bool {
+	if len(login) == 0 {
+		return user.GetType() == botType
+	}
+
+	return strings.EqualFold(user.GetLogin(), login)
+}
+
+// approvals replays the approve instructions of all comments in chronological order,
+// and returns the lowercased logins of users whose approval is still in effect.
+func approvals(comments []*github.IssueComment, current *github.IssueComment) sets.Set[string] {
+	// the triggering comment may not be listed yet due to eventual consistency
+	if current != nil && !slices.ContainsFunc(comments, func(c *github.IssueComment) bool {
+		return c.GetID() == current.GetID()
+	}) {
+		comments = append(comments, current)
+	}
+
+	approved := sets.New[string]()
+	for _, c := range comments {
+		cancel, ok := commands.MatchInstruction(commands.Parse(c.GetBody()), approveCommand)
+		if !ok {
+			continue
+		}
+
+		login := strings.ToLower(c.GetUser().GetLogin())
+		if cancel {
+			approved.Delete(login)
+		} else {
+			approved.Insert(login)
+		}
+	}
+
+	return approved
+}
+
+func statusBody(isApproved bool, approved sets.Set[string], pending map[string][]string) string {
+	var b strings.Builder
+	b.WriteString(statusMarker + "\n")
+
+	if isApproved {
+		b.WriteString("**APPROVAL STATUS**: :white_check_mark: This pull request has been approved\n\n")
+	} else {
+		b.WriteString("**APPROVAL STATUS**: :hourglass: This pull request is not approved yet\n\n")
+	}
+
+	if approved.Len() > 0 {
+		var users []string
+		for _, user := range sets.List(approved) {
+			users = append(users, "@"+user)
+		}
+		fmt.Fprintf(&b, "Approved by: %s\n\n", strings.Join(users, ", "))
+	}
+
+	if len(pending) > 0 {
+		b.WriteString("The following OWNERS files still need an approval from one of their approvers:\n\n")
+
+		paths := make([]string, 0, len(pending))
+		for p := range pending {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+
+		for _, p := range paths {
+			if len(pending[p]) == 0 {
+				fmt.Fprintf(&b, "- `%s`: no approvers, the files must be covered by an OWNERS file listing approvers\n", p)
+				continue
+			}
+
+			var users []string
+			for _, user := range pending[p] {
+				users = append(users, "`"+user+"`")
+			}
+			fmt.Fprintf(&b, "- `%s`: %s\n", p, strings.Join(users, ", "))
+		}
+		b.WriteString("\nApprovers can indicate their approval by writing `/approve` in a comment, and cancel it with `/approve cancel`.\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,385 @@
+package approve
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/gookit/slog"
+	"github.com/gookit/slog/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
+)
+
+func TestParseOwners(t *testing.T) {
+	o, err := parseOwners([]byte(`
+approvers:
+  - alice
+  - bob
+reviewers:
+  - carol
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, o.Approvers)
+	assert.Equal(t, []string{"carol"}, o.Reviewers)
+
+	_, err = parseOwners([]byte("approvers: [alice"))
+	assert.Error(t, err)
+}
+
+func TestAncestorDirs(t *testing.T) {
+	assert.Equal(t, []string{""}, ancestorDirs("main.go"))
+	assert.Equal(t, []string{"internal/actors", "internal", ""}, ancestorDirs("internal/actors/util.go"))
+}
+
+func TestPendingOwners(t *testing.T) {
+	ownersMap := map[string]*owners{
+		"":         {Approvers: []string{"root"}},
+		"internal": {Approvers: []string{"Alice", "bob"}},
+		"docs":     {Approvers: []string{"carol"}},
+	}
+	files := []string{"internal/cmd.go", "internal/actors/util.go", "docs/README.md", "main.go"}
+
+	cases := []struct {
+		caseName string
+		approved sets.Set[string]
+		expect   map[string][]string
+	}{
+		{
+			caseName: "nobody approved",
+			approved: sets.New[string](),
+			expect: map[string][]string{
+				"OWNERS":          {"root"},
+				"internal/OWNERS": {"alice", "bob", "root"},
+				"docs/OWNERS":     {"carol", "root"},
+			},
+		},
+		{
+			caseName: "approvers of a sub directory approved",
+			approved: sets.New("alice", "carol"),
+			expect: map[string][]string{
+				"OWNERS": {"root"},
+			},
+		},
+		{
+			caseName: "root approvers approve everything",
+			approved: sets.New("root"),
+			expect:   map[string][]string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			assert.Equal(t, tc.expect, pendingOwners(files, ownersMap, tc.approved))
+		})
+	}
+}
+
+func TestPendingOwnersWithoutRootOwners(t *testing.T) {
+	ownersMap := map[string]*owners{
+		"docs": {Approvers: []string{"carol"}},
+	}
+	files := []string{"docs/README.md", "main.go"}
+
+	// nobody is entitled to approve the files which are not covered by any OWNERS file
+	assert.Equal(t, map[string][]string{"OWNERS": {}}, pendingOwners(files, ownersMap, sets.New("carol", "dave")))
+	assert.Equal(t, map[string][]string{"OWNERS": {}}, pendingOwners([]string{"main.go"}, map[string]*owners{}, sets.New("dave")))
+}
+
+func TestApprovers(t *testing.T) {
+	ownersMap := map[string]*owners{
+		"":     {Approvers: []string{"Root"}, Reviewers: []string{"dave"}},
+		"docs": {Approvers: []string{"carol", "root"}},
+	}
+
+	assert.Equal(t, sets.New("root", "carol"), approvers(ownersMap))
+	assert.Empty(t, approvers(map[string]*owners{}))
+}
+
+func TestApprovals(t *testing.T) {
+	comment := func(id int64, login, body string) *github.IssueComment {
+		return &github.IssueComment{
+			ID:   github.Ptr(id),
+			Body: github.Ptr(body),
+			User: &github.User{Login: github.Ptr(login)},
+		}
+	}
+
+	comments := []*github.IssueComment{
+		comment(1, "Alice", "/approve"),
+		comment(2, "bob", "/approve"),
+		comment(3, "carol", "nice work"),
+		comment(4, "bob", "/approve cancel"),
+	}
+
+	assert.Equal(t, sets.New("alice"), approvals(comments, comments[3]))
+	assert.Equal(t, sets.New("alice", "dave"), approvals(comments, comment(5, "dave", "/approve")))
+	assert.Equal(t, sets.New("alice"), approvals(comments, nil))
+}
+
+func TestApproveCapture(t *testing.T) {
+	cases := []struct {
+		caseName string
+		event    actors.GenericEvent
+		expect   bool
+	}{
+		{
+			caseName: "approve actor capture and handle approve events",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/approve"),
+					},
+					Issue: &github.Issue{
+						PullRequestLinks: &github.PullRequestLinks{},
+					},
+				},
+			},
+			expect: true,
+		},
+		{
+			caseName: "approve actor capture and handle approve cancel events",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/approve cancel"),
+					},
+					Issue: &github.Issue{
+						PullRequestLinks: &github.PullRequestLinks{},
+					},
+				},
+			},
+			expect: true,
+		},
+		{
+			caseName: "approve actor capture pushes to pull request",
+			event: actors.GenericEvent{
+				Event: github.PullRequestEvent{
+					Action: github.Ptr("synchronize"),
+				},
+			},
+			expect: true,
+		},
+		{
+			caseName: "approve actor does not capture labeled pull request",
+			event: actors.GenericEvent{
+				Event: github.PullRequestEvent{
+					Action: github.Ptr("labeled"),
+				},
+			},
+			expect: false,
+		},
+		{
+			caseName: "approve actor does not capture issue that are not pull request",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/approve"),
+					},
+					Issue: &github.Issue{},
+				},
+			},
+			expect: false,
+		},
+		{
+			caseName: "approve actor does not capture unmatched comment body",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/approved"),
+					},
+					Issue: &github.Issue{
+						PullRequestLinks: &github.PullRequestLinks{},
+					},
+				},
+			},
+			expect: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			approveActor := &actor{
+				// a noop logger for testing only
+				logger: slog.NewWithConfig(func(l *slog.Logger) {
+					l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+				}),
+			}
+			event := tc.event
+			if commentEvent, ok := event.Event.(github.IssueCommentEvent); ok {
+				event.Commands = commands.Parse(commentEvent.GetComment().GetBody())
+			}
+			assert.Equal(t, tc.expect, approveActor.Capture(context.Background(), event))
+		})
+	}
+}
+
+func TestApproveHandler(t *testing.T) {
+	const (
+		approvedStatus = statusMarker + "\n" +
+			"**APPROVAL STATUS**: :white_check_mark: This pull request has been approved\n\n" +
+			"Approved by: @bob\n\n"
+		pendingStatus = statusMarker + "\n" +
+			"**APPROVAL STATUS**: :hourglass: This pull request is not approved yet\n\n" +
+			"The following OWNERS files still need an approval from one of their approvers:\n\n" +
+			"- `docs/OWNERS`: `bob`, `carol`\n\n" +
+			"Approvers can indicate their approval by writing `/approve` in a comment, and cancel it with `/approve cancel`.\n"
+	)
+
+	cases := []struct {
+		caseName    string
+		event       any
+		body        string
+		comments    []*githubtest.Comment
+		issueLabels []string
+		noOwners    bool
+		// installationToken makes the authenticated user unreadable like the GITHUB_TOKEN of workflows
+		installationToken bool
+
+		expectLabels    []string
+		expectComments  []string
+		expectReactions map[int64][]string
+	}{
+		{
+			caseName:        "approve of an approver approves the pull request",
+			event:           newCommentEvent("bob", "/approve"),
+			body:            "/approve",
+			expectLabels:    []string{"approved"},
+			expectComments:  []string{approvedStatus},
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName:        "approve of a user who is not an approver does not approve the pull request",
+			event:           newCommentEvent("dave", "/approve"),
+			body:            "/approve",
+			expectComments:  []string{pendingStatus},
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName: "approve does not approve the pull request without OWNERS files",
+			event:    newCommentEvent("dave", "/approve"),
+			body:     "/approve",
+			noOwners: true,
+			expectComments: []string{statusMarker + "\n" +
+				"**APPROVAL STATUS**: :hourglass: This pull request is not approved yet\n\n" +
+				"The following OWNERS files still need an approval from one of their approvers:\n\n" +
+				"- `OWNERS`: no approvers, the files must be covered by an OWNERS file listing approvers\n\n" +
+				"Approvers can indicate their approval by writing `/approve` in a comment, and cancel it with `/approve cancel`.\n"},
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName: "approve cancel removes the label and edits the status comment",
+			event:    newCommentEvent("bob", "/approve cancel"),
+			body:     "/approve cancel",
+			comments: []*githubtest.Comment{
+				{ID: 8, User: "bob", Body: "/approve"},
+				{ID: 9, User: githubtest.BotLogin, Body: approvedStatus},
+			},
+			issueLabels: []string{"approved"},
+
+			expectComments:  []string{"/approve", pendingStatus},
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName: "a status comment written by a user is not edited",
+			event:    newCommentEvent("bob", "/approve"),
+			body:     "/approve",
+			comments: []*githubtest.Comment{
+				{ID: 9, User: "dave", Body: statusMarker + "\nforged"},
+			},
+
+			expectLabels:    []string{"approved"},
+			expectComments:  []string{statusMarker + "\nforged", approvedStatus},
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName: "the status comment of a bot account is edited with an installation token",
+			event:    newCommentEvent("bob", "/approve cancel"),
+			body:     "/approve cancel",
+			comments: []*githubtest.Comment{
+				{ID: 8, User: "bob", Body: "/approve"},
+				{ID: 9, User: "dave", Body: statusMarker + "\nforged"},
+				{ID: 11, User: githubtest.BotLogin, Body: approvedStatus},
+			},
+			issueLabels:       []string{"approved"},
+			installationToken: true,
+
+			expectComments:  []string{"/approve", statusMarker + "\nforged", pendingStatus},
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName: "new commits refresh the approval state",
+			event: github.PullRequestEvent{
+				Action: github.Ptr("synchronize"),
+				Repo:   &github.Repository{FullName: github.Ptr("foo/bar")},
+				PullRequest: &github.PullRequest{
+					Number: github.Ptr(1),
+					Base:   &github.PullRequestBranch{Ref: github.Ptr("main")},
+				},
+			},
+			comments: []*githubtest.Comment{
+				{ID: 8, User: "bob", Body: "/approve"},
+			},
+
+			expectLabels:   []string{"approved"},
+			expectComments: []string{"/approve", approvedStatus},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := githubtest.NewServer(t)
+			repo := server.Repo("foo/bar")
+			if tc.installationToken {
+				server.Fail("GET /user", http.StatusForbidden)
+			}
+			issue := repo.AddPullRequest(1, "alice", "abc")
+			issue.PullRequest.Files = []string{"docs/index.md"}
+			issue.Labels = tc.issueLabels
+			issue.Comments = tc.comments
+			if !tc.noOwners {
+				repo.AddFile("main", "docs/OWNERS", "approvers:\n  - carol\n  - bob\n")
+			}
+
+			approveActor := NewApproveActor(server.Client(), slog.NewWithConfig(func(l *slog.Logger) {
+				l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+			}), config.Default())
+			event := actors.GenericEvent{Event: tc.event, Commands: commands.Parse(tc.body)}
+			require.True(t, approveActor.Capture(context.Background(), event))
+			require.NoError(t, approveActor.Handler(context.Background()))
+
+			assert.ElementsMatch(t, tc.expectLabels, issue.Labels)
+			assert.Equal(t, tc.expectComments, issue.CommentBodies())
+			if tc.expectReactions == nil {
+				assert.Empty(t, repo.Reactions)
+			} else {
+				assert.Equal(t, tc.expectReactions, repo.Reactions)
+			}
+		})
+	}
+}
+
+// newCommentEvent returns a comment of the user on the pull request #1 of alice
+func newCommentEvent(user, body string) github.IssueCommentEvent {
+	return github.IssueCommentEvent{
+		Repo: &github.Repository{FullName: github.Ptr("foo/bar")},
+		Issue: &github.Issue{
+			Number:           github.Ptr(1),
+			User:             &github.User{Login: github.Ptr("alice")},
+			PullRequestLinks: &github.PullRequestLinks{},
+		},
+		Comment: &github.IssueComment{
+			ID:   github.Ptr[int64](10),
+			User: &github.User{Login: github.Ptr(user)},
+			Body: github.Ptr(body),
+		},
+	}
+}
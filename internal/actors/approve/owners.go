@@ -0,0 +1,107 @@
+package approve
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const ownersFileName = "OWNERS"
+
+// owners represents the content of an OWNERS file
+type owners struct {
+	// Approvers users who are allowed to approve changes of the directory
+	Approvers []string `yaml:"approvers"`
+
+	// Reviewers users who are suggested to review changes of the directory
+	Reviewers []string `yaml:"reviewers"`
+}
+
+func parseOwners(content []byte) (*owners, error) {
+	var o owners
+	if err := yaml.Unmarshal(content, &o); err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+// ownersPath returns the path of the OWNERS file inside the directory
+func ownersPath(dir string) string {
+	if len(dir) == 0 {
+		return ownersFileName
+	}
+
+	return path.Join(dir, ownersFileName)
+}
+
+// ancestorDirs returns the directory of the file and all of its parent directories,
+// from the nearest one up to the repository root (represented by an empty string).
+func ancestorDirs(file string) []string {
+	var dirs []string
+	for dir := path.Dir(file); ; dir = path.Dir(dir) {
+		if dir == "." || dir == "/" {
+			dirs = append(dirs, "")
+			return dirs
+		}
+		dirs = append(dirs, dir)
+	}
+}
+
+// pendingOwners computes the OWNERS files that still need an approval.
+// Approvers listed in an OWNERS file are allowed to approve all changes below its directory,
+// so a file counts as approved once anyone from its nearest OWNERS file or any parent OWNERS file approved.
+// The result is keyed by the path of the nearest OWNERS file and lists the approvers that can unblock it.
+// Files which are not covered by any OWNERS file are kept pending under the root OWNERS file without approvers,
+// since nobody is entitled to approve them.
+func pendingOwners(files []string, ownersMap map[string]*owners, approved sets.Set[string]) map[string][]string {
+	pending := make(map[string][]string)
+	for _, file := range files {
+		var (
+			nearest    = ownersPath("")
+			hasOwners  bool
+			candidates = sets.New[string]()
+		)
+		for _, dir := range ancestorDirs(file) {
+			o, ok := ownersMap[dir]
+			if !ok {
+				continue
+			}
+			if !hasOwners {
+				nearest = ownersPath(dir)
+				hasOwners = true
+			}
+			for _, approver := range o.Approvers {
+				candidates.Insert(strings.ToLower(approver))
+			}
+		}
+
+		if candidates.HasAny(approved.UnsortedList()...) {
+			continue
+		}
+
+		if existing, ok := pending[nearest]; ok {
+			candidates.Insert(existing...)
+		}
+		list := candidates.UnsortedList()
+		sort.Strings(list)
+		pending[nearest] = list
+	}
+
+	return pending
+}
+
+// approvers returns the approvers listed in any of the OWNERS files, only their approvals count
+func approvers(ownersMap map[string]*owners) sets.Set[string] {
+	result := sets.New[string]()
+	for _, o := range ownersMap {
+		for _, approver := range o.Approvers {
+			result.Insert(strings.ToLower(approver))
+		}
+	}
+
+	return result
+}
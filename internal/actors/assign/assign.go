@@ -2,123 +2,227 @@ package assign
 
 import (
 	"context"
-	"fmt"
-	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/google/go-github/v72/github"
 	"github.com/gookit/slog"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
 )
 
 const (
-	assignActorName = "AssignActor"
-)
+	assignActorName = "assign"
+
+	assignedAction = "assigned"
 
-var assignRegexp = regexp.MustCompile(`^/(un)?assign\b`)
+	assignCommand   = "assign"
+	unassignCommand = "unassign"
+)
 
 type actor struct {
 	ghClient *github.Client
 	logger   *slog.Logger
+	cfg      *config.Config
 
-	event github.IssueCommentEvent
-	add   bool
+	event       github.IssueCommentEvent
+	issuesEvent *github.IssuesEvent
+	add         bool
+
+	// targets the logins of the users mentioned by the instruction, the commenter is the target when it is empty
+	targets []string
 }
 
-func NewAssignActor(ghClient *github.Client, logger *slog.Logger) actors.Actor {
+func NewAssignActor(ghClient *github.Client, logger *slog.Logger, cfg *config.Config) actors.Actor {
 	return &actor{
 		ghClient: ghClient,
 		logger:   logger,
+		cfg:      cfg,
 		add:      true,
 	}
 }
 
-func (a *actor) Handler() error {
+func (a *actor) Handler(ctx context.Context) error {
+	if a.issuesEvent != nil {
+		return a.handleAssigned(ctx)
+	}
+
 	var (
 		issue     = a.event.GetIssue()
-		comment   = a.event.GetComment()
-		loginUser = comment.GetUser()
-		repo      = a.event.GetRepo()
-		assignees = issue.Assignees
+		loginUser = a.event.GetComment().GetUser().GetLogin()
+		targets   = a.targets
 	)
 	a.logger.Infof("actor %s started processing events, issue number: #%d", a.Name(), issue.GetNumber())
 
-	owner, repoName := actors.GetOwnerRepo(repo.GetFullName())
+	if len(targets) == 0 {
+		targets = []string{loginUser}
+	}
+
 	if a.add {
+		return a.assign(ctx, loginUser, targets)
+	}
+	return a.unassign(ctx, loginUser, targets)
+}
+
+// Exempt anyone may assign and unassign themselves,
+// assigning or unassigning other users requires the permission requirement of the actor.
+func (a *actor) Exempt() bool {
+	loginUser := a.event.GetComment().GetUser().GetLogin()
+	return !slices.ContainsFunc(a.targets, func(target string) bool { return !strings.EqualFold(target, loginUser) })
+}
+
+// assign assigns the targets who are not assigned yet, and replies with the targets who cannot be assigned
+func (a *actor) assign(ctx context.Context, loginUser string, targets []string) error {
+	var (
+		issue           = a.event.GetIssue()
+		comment         = a.event.GetComment()
+		fullName        = a.event.GetRepo().GetFullName()
+		owner, repoName = actors.GetOwnerRepo(fullName)
+		replyData       = config.ReplyData{User: loginUser, Label: a.cfg.Labels.HelpWanted}
+	)
+
+	var pending []string
+	for _, target := range targets {
+		if !isAssigned(target, issue.Assignees) {
+			pending = append(pending, target)
+		}
+	}
+	if len(pending) == 0 {
 		// if it has been assigned to the login user, we will write back a comment
-		if isAssignLoginUser(loginUser, assignees) {
-			err := actors.AddComment(
-				a.ghClient,
-				fmt.Sprintf("@%s %s", loginUser.GetLogin(), "The issue has been assigned to you. Please do not attempt to assign it"),
-				repo.GetFullName(),
-				issue.GetNumber(),
-			)
-			return err
+		if len(targets) == 1 && strings.EqualFold(targets[0], loginUser) {
+			reply, err := config.RenderReply(a.cfg.Replies.AlreadyAssigned, replyData)
+			if err != nil {
+				return err
+			}
+			return actors.AddComment(ctx, a.ghClient, reply, fullName, issue.GetNumber())
 		}
+		return nil
+	}
 
-		if _, _, err := a.ghClient.Issues.AddAssignees(
-			context.Background(),
-			owner,
-			repoName,
-			issue.GetNumber(),
-			[]string{loginUser.GetLogin()},
-		); err != nil {
-			return err
+	// the commenter is assignable through the comment itself, the other users must be assignable to the repository
+	var assignable, unassignable []string
+	for _, target := range pending {
+		if strings.EqualFold(target, loginUser) {
+			assignable = append(assignable, target)
+			continue
 		}
-		a.logger.Infof("assigned issue to '%s'", loginUser.GetLogin())
-
-		if err := actors.AddReaction(a.ghClient, actors.CommendReaction, repo.GetFullName(), comment.GetID()); err != nil {
+		ok, _, err := a.ghClient.Issues.IsAssignee(ctx, owner, repoName, target)
+		if err != nil {
 			return err
 		}
-		a.logger.Infof("add a reaction '%s' to comment %d of issue #%d", actors.CommendReaction, comment.GetID(), issue.GetNumber())
+		if ok {
+			assignable = append(assignable, target)
+		} else {
+			unassignable = append(unassignable, target)
+		}
+	}
 
-		if err := actors.RemoveLabelToIssue(a.ghClient, repo.GetFullName(), issue.GetNumber(), actors.HelpWantedLabel); err != nil {
+	if len(assignable) != 0 {
+		if _, _, err := a.ghClient.Issues.AddAssignees(ctx, owner, repoName, issue.GetNumber(), assignable); err != nil {
 			return err
 		}
-		a.logger.Infof("remove '%s' label from issue #%d", actors.HelpWantedLabel, issue.GetNumber())
-	} else {
-		// if it has been unassigned to the login user, we will write back a comment
-		if !isAssignLoginUser(loginUser, assignees) {
-			err := actors.AddComment(
-				a.ghClient,
-				fmt.Sprintf("@%s %s", loginUser.GetLogin(), "This issue is no assigned to you. Please do not try to unassign it again"),
-				repo.GetFullName(),
-				issue.GetNumber(),
-			)
+		a.logger.Infof("assigned issue #%d to [%s]", issue.GetNumber(), strings.Join(assignable, ","))
+
+		if err := actors.AddReaction(ctx, a.ghClient, a.cfg.Reactions.Commend, fullName, comment.GetID()); err != nil {
 			return err
 		}
+		a.logger.Infof("add a reaction '%s' to comment %d of issue #%d", a.cfg.Reactions.Commend, comment.GetID(), issue.GetNumber())
 
-		if _, _, err := a.ghClient.Issues.RemoveAssignees(
-			context.Background(),
-			owner,
-			repoName,
-			issue.GetNumber(),
-			[]string{loginUser.GetLogin()},
-		); err != nil {
-			return err
+		// pull requests do not ask for help
+		if !issue.IsPullRequest() {
+			if err := actors.RemoveLabelToIssue(ctx, a.ghClient, fullName, issue.GetNumber(), a.cfg.Labels.HelpWanted); err != nil {
+				return err
+			}
+			a.logger.Infof("remove '%s' label from issue #%d", a.cfg.Labels.HelpWanted, issue.GetNumber())
 		}
+	}
 
-		if err := actors.AddLabelToIssue(a.ghClient, repo.GetFullName(), issue.GetNumber(), actors.HelpWantedLabel); err != nil {
-			return err
+	if len(unassignable) == 0 {
+		return nil
+	}
+	replyData.Users = strings.Join(unassignable, ",")
+	reply, err := config.RenderReply(a.cfg.Replies.NotAssignable, replyData)
+	if err != nil {
+		return err
+	}
+	return actors.AddComment(ctx, a.ghClient, reply, fullName, issue.GetNumber())
+}
+
+// unassign unassigns the targets who are assigned, and asks for help again once nobody is assigned anymore
+func (a *actor) unassign(ctx context.Context, loginUser string, targets []string) error {
+	var (
+		issue           = a.event.GetIssue()
+		fullName        = a.event.GetRepo().GetFullName()
+		owner, repoName = actors.GetOwnerRepo(fullName)
+	)
+
+	var assigned []string
+	for _, target := range targets {
+		if isAssigned(target, issue.Assignees) {
+			assigned = append(assigned, target)
 		}
-		a.logger.Infof("add '%s' label from issue #%d", actors.HelpWantedLabel, issue.GetNumber())
+	}
+	if len(assigned) == 0 {
+		// if it has been unassigned to the login user, we will write back a comment
+		if len(targets) == 1 && strings.EqualFold(targets[0], loginUser) {
+			reply, err := config.RenderReply(a.cfg.Replies.NotAssigned, config.ReplyData{User: loginUser, Label: a.cfg.Labels.HelpWanted})
+			if err != nil {
+				return err
+			}
+			return actors.AddComment(ctx, a.ghClient, reply, fullName, issue.GetNumber())
+		}
+		return nil
+	}
 
-		a.logger.Infof("unassigned issue to '%s'", loginUser.GetLogin())
+	if _, _, err := a.ghClient.Issues.RemoveAssignees(ctx, owner, repoName, issue.GetNumber(), assigned); err != nil {
+		return err
 	}
+	a.logger.Infof("unassigned issue #%d from [%s]", issue.GetNumber(), strings.Join(assigned, ","))
+
+	if len(assigned) < len(issue.Assignees) || issue.IsPullRequest() {
+		return nil
+	}
+	if err := actors.AddLabelToIssue(ctx, a.ghClient, fullName, issue.GetNumber(), a.cfg.Labels.HelpWanted); err != nil {
+		return err
+	}
+	a.logger.Infof("add '%s' label from issue #%d", a.cfg.Labels.HelpWanted, issue.GetNumber())
 
 	return nil
 }
 
-func (a *actor) Capture(event actors.GenericEvent) bool {
+// handleAssigned removes the help wanted label once the issue has been assigned without the assign instruction,
+// e.g. by a maintainer through the GitHub UI.
+func (a *actor) handleAssigned(ctx context.Context) error {
+	var (
+		issue = a.issuesEvent.GetIssue()
+		repo  = a.issuesEvent.GetRepo()
+	)
+	a.logger.Infof("actor %s started processing events, issue number: #%d", a.Name(), issue.GetNumber())
+
+	if err := actors.RemoveLabelToIssue(ctx, a.ghClient, repo.GetFullName(), issue.GetNumber(), a.cfg.Labels.HelpWanted); err != nil {
+		return err
+	}
+	a.logger.Infof("remove '%s' label from issue #%d since it was assigned to '%s'", a.cfg.Labels.HelpWanted, issue.GetNumber(), a.issuesEvent.GetAssignee().GetLogin())
+
+	return nil
+}
+
+func (a *actor) Capture(ctx context.Context, event actors.GenericEvent) bool {
 	genericEvent := event.Event
+	if issuesEvent, ok := genericEvent.(github.IssuesEvent); ok {
+		return a.captureIssues(issuesEvent)
+	}
+
 	commentEvent, ok := genericEvent.(github.IssueCommentEvent)
 	if !ok {
 		a.logger.Error("cannot extract event to github.IssueCommentEvent, please check event type")
 		return false
 	}
 
-	// pull request is essentially an issue, and the current actor does not handle this situation.
-	if commentEvent.Issue.IsPullRequest() {
+	// pull request is essentially an issue, assigning it is up to the configuration
+	if commentEvent.Issue.IsPullRequest() && !a.cfg.Assign.PullRequests {
 		return false
 	}
 
@@ -132,13 +236,17 @@ func (a *actor) Capture(event actors.GenericEvent) bool {
 		return false
 	}
 
-	matches := assignRegexp.FindAllStringSubmatch(comment.GetBody(), -1)
-	if matches == nil {
+	cmds := commands.Filter(event.Commands, assignCommand, unassignCommand)
+	if len(cmds) == 0 {
 		return false
 	}
-	for _, match := range matches {
-		if match[1] == "un" {
-			a.add = false
+	// the last instruction wins
+	cmd := cmds[len(cmds)-1]
+	a.add = cmd.Name == assignCommand
+	a.targets = nil
+	for _, mention := range cmd.Mentions() {
+		if !slices.ContainsFunc(a.targets, func(target string) bool { return strings.EqualFold(target, mention) }) {
+			a.targets = append(a.targets, mention)
 		}
 	}
 	a.event = commentEvent
@@ -146,17 +254,32 @@ func (a *actor) Capture(event actors.GenericEvent) bool {
 	return true
 }
 
+func (a *actor) captureIssues(issuesEvent github.IssuesEvent) bool {
+	if issuesEvent.GetAction() != assignedAction {
+		return false
+	}
+
+	// nothing to do when the issue is not waiting for help
+	if !actors.HasLabel(issuesEvent.GetIssue().Labels, a.cfg.Labels.HelpWanted) {
+		return false
+	}
+	a.issuesEvent = &issuesEvent
+
+	return true
+}
+
 func (a *actor) Name() string {
 	return assignActorName
 }
 
-func isAssignLoginUser(user *github.User, assignees []*github.User) bool {
-	if len(assignees) == 0 {
-		return false
-	}
+func (a *actor) Commands() []string {
+	return []string{assignCommand, unassignCommand}
+}
 
+// isAssigned reports whether the user is one of the assignees, logins are case-insensitive
+func isAssigned(login string, assignees []*github.User) bool {
 	for _, assignee := range assignees {
-		if assignee.GetID() == user.GetID() {
+		if strings.EqualFold(assignee.GetLogin(), login) {
 			return true
 		}
 	}
@@ -1,6 +1,7 @@
 package assign
 
 import (
+	"context"
 	"io"
 	"testing"
 	"time"
@@ -9,35 +10,39 @@ import (
 	"github.com/gookit/slog"
 	"github.com/gookit/slog/handler"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
 )
 
 func TestAssignCommentBodyMatch(t *testing.T) {
 	cases := []struct {
 		caseName string
 		comment  string
-		expect   [][]string
+		expect   []string
 	}{
 		{
 			caseName: "Match the assign instruction",
 			comment:  "/assign",
-			expect: [][]string{
-				{
-					"/assign",
-					"",
-				},
-			},
+			expect:   []string{assignCommand},
 		},
 		{
 			caseName: "Match the unassign instruction",
 			comment:  "/unassign",
-			expect: [][]string{
-				{
-					"/unassign",
-					"un",
-				},
-			},
+			expect:   []string{unassignCommand},
+		},
+		{
+			caseName: "Match the assign instruction on any line",
+			comment:  "I would like to work on it\n/assign",
+			expect:   []string{assignCommand},
+		},
+		{
+			caseName: "Skip the assign instruction in quoted reply",
+			comment:  "> /assign",
+			expect:   nil,
 		},
 		{
 			caseName: "unmatched instructions",
@@ -48,22 +53,21 @@ func TestAssignCommentBodyMatch(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.caseName, func(t *testing.T) {
-			match := assignRegexp.FindAllStringSubmatch(tc.comment, -1)
-			if tc.expect != nil {
-				assert.NotNil(t, match)
-				assert.ElementsMatch(t, tc.expect, match)
-			} else {
-				assert.Nil(t, match)
+			var names []string
+			for _, cmd := range commands.Filter(commands.Parse(tc.comment), assignCommand, unassignCommand) {
+				names = append(names, cmd.Name)
 			}
+			assert.Equal(t, tc.expect, names)
 		})
 	}
 }
 
 func TestAssignCapture(t *testing.T) {
 	cases := []struct {
-		caseName string
-		event    actors.GenericEvent
-		expect   bool
+		caseName            string
+		event               actors.GenericEvent
+		disablePullRequests bool
+		expect              bool
 	}{
 		{
 			caseName: "assign actor capture and handle events",
@@ -78,7 +82,44 @@ func TestAssignCapture(t *testing.T) {
 			expect: true,
 		},
 		{
-			caseName: "assign actor does not capture pull request",
+			caseName: "assign actor capture issue assigned with help wanted label",
+			event: actors.GenericEvent{
+				Event: github.IssuesEvent{
+					Action: github.Ptr("assigned"),
+					Issue: &github.Issue{
+						Labels: []*github.Label{{Name: github.Ptr(actors.HelpWantedLabel)}},
+					},
+				},
+			},
+			expect: true,
+		},
+		{
+			caseName: "assign actor does not capture issue assigned without help wanted label",
+			event: actors.GenericEvent{
+				Event: github.IssuesEvent{
+					Action: github.Ptr("assigned"),
+					Issue:  &github.Issue{},
+				},
+			},
+			expect: false,
+		},
+		{
+			caseName: "assign actor capture pull request",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/assign"),
+					},
+					Issue: &github.Issue{
+						PullRequestLinks: &github.PullRequestLinks{},
+					},
+				},
+			},
+			expect: true,
+		},
+		{
+			caseName:            "assign actor does not capture pull request when disabled",
+			disablePullRequests: true,
 			event: actors.GenericEvent{
 				Event: github.IssueCommentEvent{
 					Comment: &github.IssueComment{
@@ -118,7 +159,7 @@ func TestAssignCapture(t *testing.T) {
 			expect: false,
 		},
 		{
-			caseName: "assign actor does not capture unmatched assignRegexp comment body issue",
+			caseName: "assign actor does not capture unmatched assign command comment body issue",
 			event: actors.GenericEvent{
 				Event: github.IssueCommentEvent{
 					Comment: &github.IssueComment{
@@ -133,13 +174,223 @@ func TestAssignCapture(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.caseName, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Assign.PullRequests = !tc.disablePullRequests
 			assignActor := &actor{
+				cfg: cfg,
 				// a noop logger for testing only
 				logger: slog.NewWithConfig(func(l *slog.Logger) {
 					l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
 				}),
 			}
-			assert.Equal(t, tc.expect, assignActor.Capture(tc.event))
+			event := tc.event
+			if commentEvent, ok := event.Event.(github.IssueCommentEvent); ok {
+				event.Commands = commands.Parse(commentEvent.GetComment().GetBody())
+			}
+			assert.Equal(t, tc.expect, assignActor.Capture(context.Background(), event))
+		})
+	}
+}
+
+func TestAssignHandler(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		event       any
+		pullRequest bool
+		assignees   []string
+		roles       map[string]string
+
+		expectAssignees []string
+		expectLabels    []string
+		expectComments  []string
+		expectReactions []string
+	}{
+		{
+			caseName:        "assign the commenter and drop the help wanted label",
+			event:           newCommentEvent("/assign"),
+			expectAssignees: []string{"bob"},
+			expectLabels:    []string{},
+			expectReactions: []string{actors.CommendReaction},
+		},
+		{
+			caseName:        "assign the commenter mentioning themselves without being a collaborator",
+			event:           newCommentEvent("/assign @Bob"),
+			expectAssignees: []string{"Bob"},
+			expectLabels:    []string{},
+			expectReactions: []string{actors.CommendReaction},
+		},
+		{
+			caseName:        "reply when the commenter is already assigned",
+			event:           newCommentEvent("/assign", "bob"),
+			assignees:       []string{"bob"},
+			expectAssignees: []string{"bob"},
+			expectLabels:    []string{},
+			expectComments:  []string{"@bob The issue has been assigned to you. Please do not attempt to assign it"},
+		},
+		{
+			caseName:        "collaborator assigns other users and is told who cannot be assigned",
+			event:           newCommentEvent("/assign @carol @dave @carol"),
+			roles:           map[string]string{"bob": "triage", "carol": "read"},
+			expectAssignees: []string{"carol"},
+			expectLabels:    []string{},
+			expectComments:  []string{"@bob These users '(dave)' cannot be assigned, only users with access to the repository can be assigned"},
+			expectReactions: []string{actors.CommendReaction},
+		},
+		{
+			caseName:       "nobody is assigned when no user can be assigned",
+			event:          newCommentEvent("/assign @dave"),
+			roles:          map[string]string{"bob": "write"},
+			expectLabels:   []string{actors.HelpWantedLabel},
+			expectComments: []string{"@bob These users '(dave)' cannot be assigned, only users with access to the repository can be assigned"},
+		},
+
+		{
+			caseName:        "unassign the commenter and ask for help again",
+			event:           newCommentEvent("/unassign", "bob"),
+			assignees:       []string{"bob"},
+			expectAssignees: []string{},
+			expectLabels:    []string{actors.HelpWantedLabel},
+		},
+		{
+			caseName:        "collaborator unassigns another user who is not the only assignee",
+			event:           newCommentEvent("/unassign @carol", "bob", "carol"),
+			assignees:       []string{"bob", "carol"},
+			roles:           map[string]string{"bob": "maintain"},
+			expectAssignees: []string{"bob"},
+			expectLabels:    []string{},
+		},
+
+		{
+			caseName:       "reply when the commenter is not assigned",
+			event:          newCommentEvent("/unassign"),
+			expectLabels:   []string{actors.HelpWantedLabel},
+			expectComments: []string{"@bob This issue is no assigned to you. Please do not try to unassign it again"},
+		},
+		{
+			caseName:        "assign a shepherd to a pull request without touching the help wanted label",
+			event:           newCommentEvent("/assign @carol"),
+			pullRequest:     true,
+			roles:           map[string]string{"bob": "triage", "carol": "write"},
+			expectAssignees: []string{"carol"},
+			expectLabels:    []string{actors.HelpWantedLabel},
+			expectReactions: []string{actors.CommendReaction},
+		},
+		{
+			caseName:        "unassign the last assignee of a pull request without asking for help",
+			event:           newCommentEvent("/unassign", "bob"),
+			pullRequest:     true,
+			assignees:       []string{"bob"},
+			expectAssignees: []string{},
+			expectLabels:    []string{},
+		},
+		{
+			caseName: "drop the help wanted label once assigned through the UI",
+			event: github.IssuesEvent{
+				Action:   github.Ptr(assignedAction),
+				Repo:     &github.Repository{FullName: github.Ptr("foo/bar")},
+				Issue:    &github.Issue{Number: github.Ptr(1), Labels: []*github.Label{{Name: github.Ptr(actors.HelpWantedLabel)}}},
+				Assignee: &github.User{Login: github.Ptr("carol")},
+			},
+			expectLabels: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := githubtest.NewServer(t)
+			repo := server.Repo("foo/bar")
+			var issue *githubtest.Issue
+			if tc.pullRequest {
+				issue = repo.AddPullRequest(1, "alice", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+			} else {
+				issue = repo.AddIssue(1, "alice")
+			}
+			issue.Assignees = tc.assignees
+			if len(tc.assignees) == 0 {
+				issue.Labels = []string{actors.HelpWantedLabel}
+			}
+			for login, role := range tc.roles {
+				repo.Permissions[login] = role
+			}
+
+			event := actors.GenericEvent{Event: tc.event}
+			if commentEvent, ok := tc.event.(github.IssueCommentEvent); ok {
+				if tc.pullRequest {
+					commentEvent.Issue.PullRequestLinks = &github.PullRequestLinks{}
+					event.Event = commentEvent
+				}
+				event.Commands = commands.Parse(commentEvent.GetComment().GetBody())
+			} else {
+				issue.Labels = []string{actors.HelpWantedLabel}
+			}
+			assignActor := NewAssignActor(server.Client(), slog.NewWithConfig(func(l *slog.Logger) {
+				l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+			}), config.Default())
+			require.True(t, assignActor.Capture(context.Background(), event))
+			require.NoError(t, assignActor.Handler(context.Background()))
+
+			assert.ElementsMatch(t, tc.expectAssignees, issue.Assignees)
+			assert.ElementsMatch(t, tc.expectLabels, issue.Labels)
+			assert.Equal(t, tc.expectComments, issue.CommentBodies())
+			assert.Equal(t, tc.expectReactions, repo.Reactions[10])
 		})
 	}
 }
+
+func TestAssignExempt(t *testing.T) {
+	cases := []struct {
+		caseName string
+		body     string
+		expect   bool
+	}{
+		{
+			caseName: "commenter assigns themselves",
+			body:     "/assign",
+			expect:   true,
+		},
+		{
+			caseName: "commenter unassigns themselves by mention",
+			body:     "/unassign @Bob",
+			expect:   true,
+		},
+		{
+			caseName: "assigning other users requires the permission",
+			body:     "/assign @bob @carol",
+			expect:   false,
+		},
+		{
+			caseName: "unassigning other users requires the permission",
+			body:     "/unassign @carol",
+			expect:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			assignActor := NewAssignActor(nil, slog.NewWithConfig(func(l *slog.Logger) {
+				l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+			}), config.Default())
+			event := actors.GenericEvent{Event: newCommentEvent(tc.body), Commands: commands.Parse(tc.body)}
+			require.True(t, assignActor.Capture(context.Background(), event))
+
+			exempter, ok := assignActor.(actors.Exempter)
+			require.True(t, ok)
+			assert.Equal(t, tc.expect, exempter.Exempt())
+		})
+	}
+}
+
+// newCommentEvent returns a comment of bob on the issue #1 of foo/bar assigned to the assignees
+func newCommentEvent(body string, assignees ...string) github.IssueCommentEvent {
+	event := github.IssueCommentEvent{
+		Action:  github.Ptr("created"),
+		Repo:    &github.Repository{FullName: github.Ptr("foo/bar")},
+		Issue:   &github.Issue{Number: github.Ptr(1)},
+		Comment: &github.IssueComment{ID: github.Ptr[int64](10), User: &github.User{Login: github.Ptr("bob")}, Body: github.Ptr(body)},
+	}
+	for _, assignee := range assignees {
+		event.Issue.Assignees = append(event.Issue.Assignees, &github.User{Login: github.Ptr(assignee)})
+	}
+
+	return event
+}
@@ -4,24 +4,27 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"regexp"
 	"strings"
 
 	"github.com/google/go-github/v72/github"
 	"github.com/gookit/slog"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
 )
 
 const (
 	ccActorName = "cc"
-)
 
-var ccRegexp = regexp.MustCompile(`(?mi)^/(un)?cc(\s+@[-/\w]+)+\s*$`)
+	ccCommand   = "cc"
+	unccCommand = "uncc"
+)
 
 type actor struct {
 	ghClient *github.Client
 	logger   *slog.Logger
+	cfg      *config.Config
 
 	event github.IssueCommentEvent
 
@@ -29,14 +32,15 @@ type actor struct {
 	reviewers []string
 }
 
-func NewCCActor(ghClient *github.Client, logger *slog.Logger) actors.Actor {
+func NewCCActor(ghClient *github.Client, logger *slog.Logger, cfg *config.Config) actors.Actor {
 	return &actor{
 		ghClient: ghClient,
 		logger:   logger,
+		cfg:      cfg,
 	}
 }
 
-func (a *actor) Handler() error {
+func (a *actor) Handler(ctx context.Context) error {
 	var (
 		issue           = a.event.GetIssue()
 		owner, repoName = actors.GetOwnerRepo(a.event.GetRepo().GetFullName())
@@ -46,7 +50,7 @@ func (a *actor) Handler() error {
 	if a.cc {
 		var statusCode int
 		_, response, err := a.ghClient.PullRequests.RequestReviewers(
-			context.Background(),
+			ctx,
 			owner,
 			repoName,
 			issue.GetNumber(),
@@ -64,7 +68,7 @@ func (a *actor) Handler() error {
 		a.logger.Infof("actor %s requested reviewers for issue #%d. reviewers: [%s]", a.Name(), issue.GetNumber(), strings.Join(a.reviewers, ","))
 	} else {
 		_, err := a.ghClient.PullRequests.RemoveReviewers(
-			context.Background(),
+			ctx,
 			owner,
 			repoName,
 			issue.GetNumber(),
@@ -81,7 +85,7 @@ func (a *actor) Handler() error {
 	return nil
 }
 
-func (a *actor) Capture(event actors.GenericEvent) bool {
+func (a *actor) Capture(ctx context.Context, event actors.GenericEvent) bool {
 	genericEvent := event.Event
 	commentEvent, ok := genericEvent.(github.IssueCommentEvent)
 	if !ok {
@@ -96,34 +100,17 @@ func (a *actor) Capture(event actors.GenericEvent) bool {
 		return false
 	}
 
-	matches := ccRegexp.FindAllStringSubmatch(commentEvent.Comment.GetBody(), -1)
-	if matches == nil {
+	cmds := commands.Filter(event.Commands, ccCommand, unccCommand)
+	if len(cmds) == 0 {
 		return false
 	}
 
 	var reviewers []string
-	for _, match := range matches {
-		// verify the command structure
-		split := strings.Split(strings.TrimSpace(match[0]), " ")
-		if len(split) < 2 || len(split[0]) == 0 {
-			return false
-		}
-
-		action := split[0]
-		if strings.HasPrefix(action, "/un") {
-			a.cc = false
-		} else {
-			a.cc = true
-		}
+	for _, cmd := range cmds {
+		a.cc = cmd.Name == ccCommand
 
 		// get the reviewers for one or more applications
-		for _, reviewer := range split[1:] {
-			user := strings.TrimPrefix(strings.TrimSpace(reviewer), "@")
-			if len(user) == 0 {
-				continue
-			}
-			reviewers = append(reviewers, user)
-		}
+		reviewers = append(reviewers, cmd.Mentions()...)
 	}
 	if len(reviewers) == 0 {
 		a.logger.Infof("actor %s has no reviewers to Assignment", a.Name())
@@ -139,3 +126,7 @@ func (a *actor) Capture(event actors.GenericEvent) bool {
 func (a *actor) Name() string {
 	return ccActorName
 }
+
+func (a *actor) Commands() []string {
+	return []string{ccCommand, unccCommand}
+}
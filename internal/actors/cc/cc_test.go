@@ -1,6 +1,7 @@
 package cc
 
 import (
+	"context"
 	"io"
 	"testing"
 
@@ -8,8 +9,12 @@ import (
 	"github.com/gookit/slog"
 	"github.com/gookit/slog/handler"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
 )
 
 func TestCcCapture(t *testing.T) {
@@ -99,7 +104,11 @@ func TestCcCapture(t *testing.T) {
 				}),
 			}
 
-			assert.Equal(t, tc.expect, ccActor.Capture(tc.event))
+			event := tc.event
+			if commentEvent, ok := event.Event.(github.IssueCommentEvent); ok {
+				event.Commands = commands.Parse(commentEvent.GetComment().GetBody())
+			}
+			assert.Equal(t, tc.expect, ccActor.Capture(context.Background(), event))
 
 			switch {
 			case len(tc.addCC) > 0:
@@ -112,3 +121,60 @@ func TestCcCapture(t *testing.T) {
 		})
 	}
 }
+
+func TestCcHandler(t *testing.T) {
+	cases := []struct {
+		caseName  string
+		body      string
+		requested []string
+
+		expectReviewers []string
+		expectErr       bool
+	}{
+		{
+			caseName:        "request reviews",
+			body:            "/cc @carol @dave",
+			expectReviewers: []string{"carol", "dave"},
+		},
+		{
+			caseName:        "remove requested reviewers",
+			body:            "/uncc @carol",
+			requested:       []string{"carol", "dave"},
+			expectReviewers: []string{"dave"},
+		},
+		{
+			caseName:  "the author cannot review the pull request",
+			body:      "/cc @alice",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := githubtest.NewServer(t)
+			pr := server.Repo("foo/bar").AddPullRequest(1, "alice", "abc")
+			pr.PullRequest.RequestedReviewers = tc.requested
+
+			event := actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Repo:    &github.Repository{FullName: github.Ptr("foo/bar")},
+					Issue:   &github.Issue{Number: github.Ptr(1), PullRequestLinks: &github.PullRequestLinks{}},
+					Comment: &github.IssueComment{ID: github.Ptr[int64](10), User: &github.User{Login: github.Ptr("bob")}, Body: github.Ptr(tc.body)},
+				},
+				Commands: commands.Parse(tc.body),
+			}
+			ccActor := NewCCActor(server.Client(), slog.NewWithConfig(func(l *slog.Logger) {
+				l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+			}), config.Default())
+			require.True(t, ccActor.Capture(context.Background(), event))
+
+			err := ccActor.Handler(context.Background())
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectReviewers, pr.PullRequest.RequestedReviewers)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package hold
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/gookit/slog"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+)
+
+const (
+	holdActorName = "hold"
+
+	openedAction      = "opened"
+	reopenedAction    = "reopened"
+	synchronizeAction = "synchronize"
+	labeledAction     = "labeled"
+	unlabeledAction   = "unlabeled"
+
+	completedStatus   = "completed"
+	failureConclusion = "failure"
+	successConclusion = "success"
+
+	holdCommand = "hold"
+)
+
+type actor struct {
+	ghClient *github.Client
+	logger   *slog.Logger
+	cfg      *config.Config
+
+	event   github.IssueCommentEvent
+	prEvent *github.PullRequestEvent
+	cancel  bool
+}
+
+func NewHoldActor(ghClient *github.Client, logger *slog.Logger, cfg *config.Config) actors.Actor {
+	return &actor{
+		ghClient: ghClient,
+		logger:   logger,
+		cfg:      cfg,
+	}
+}
+
+func (a *actor) Handler(ctx context.Context) error {
+	if a.prEvent != nil {
+		var (
+			pr   = a.prEvent.GetPullRequest()
+			repo = a.prEvent.GetRepo()
+		)
+		a.logger.Infof("actor %s started processing events, pr number: #%d", a.Name(), pr.GetNumber())
+
+		return a.updateCheckRun(ctx, repo.GetFullName(), pr.GetHead().GetSHA(), actors.HasLabel(pr.Labels, a.cfg.Labels.Hold))
+	}
+
+	var (
+		issue    = a.event.GetIssue()
+		comment  = a.event.GetComment()
+		fullName = a.event.GetRepo().GetFullName()
+	)
+	a.logger.Infof("actor %s started processing events, pr number: #%d", a.Name(), issue.GetNumber())
+
+	if a.cancel {
+		if err := actors.RemoveLabelToIssue(ctx, a.ghClient, fullName, issue.GetNumber(), a.cfg.Labels.Hold); err != nil {
+			return err
+		}
+		a.logger.Infof("remove '%s' label from pr #%d", a.cfg.Labels.Hold, issue.GetNumber())
+	} else {
+		if err := actors.AddLabelToIssue(ctx, a.ghClient, fullName, issue.GetNumber(), a.cfg.Labels.Hold); err != nil {
+			return err
+		}
+		a.logger.Infof("add '%s' label to pr #%d", a.cfg.Labels.Hold, issue.GetNumber())
+	}
+
+	if err := actors.AddReaction(ctx, a.ghClient, a.cfg.Reactions.Commend, fullName, comment.GetID()); err != nil {
+		return err
+	}
+
+	if len(a.cfg.Hold.CheckName) == 0 {
+		return nil
+	}
+	// the labels of the event are stale, so the check run follows the instruction instead
+	pr, err := actors.GetPRFromIssue(ctx, a.ghClient, fullName, issue)
+	if err != nil {
+		return err
+	}
+
+	return a.updateCheckRun(ctx, fullName, pr.GetHead().GetSHA(), !a.cancel)
+}
+
+// updateCheckRun completes the hold check run of the commit, it fails while the pull request is held
+// so that branch protection can block merging. The check run created by a previous event is updated
+// instead of adding another one with the same name.
+func (a *actor) updateCheckRun(ctx context.Context, fullName, sha string, held bool) error {
+	owner, repoName := actors.GetOwnerRepo(fullName)
+	checkName := a.cfg.Hold.CheckName
+
+	conclusion, output := successConclusion, &github.CheckRunOutput{
+		Title:   github.Ptr("Not held"),
+		Summary: github.Ptr("The pull request is not held, comment `/hold` to prevent it from being merged."),
+	}
+	if held {
+		conclusion, output = failureConclusion, &github.CheckRunOutput{
+			Title:   github.Ptr("Held"),
+			Summary: github.Ptr(fmt.Sprintf("The pull request is held by the '%s' label, comment `/hold cancel` to release it.", a.cfg.Labels.Hold)),
+		}
+	}
+
+	runs, err := actors.ListAll(func(opts github.ListOptions) ([]*github.CheckRun, *github.Response, error) {
+		checkRuns, resp, err := a.ghClient.Checks.ListCheckRunsForRef(
+			ctx,
+			owner,
+			repoName,
+			sha,
+			&github.ListCheckRunsOptions{CheckName: github.Ptr(checkName), ListOptions: opts},
+		)
+		if err != nil {
+			return nil, resp, err
+		}
+		return checkRuns.CheckRuns, resp, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		if run.GetName() != checkName {
+			continue
+		}
+		if _, _, err := a.ghClient.Checks.UpdateCheckRun(ctx, owner, repoName, run.GetID(), github.UpdateCheckRunOptions{
+			Name:       checkName,
+			Status:     github.Ptr(completedStatus),
+			Conclusion: github.Ptr(conclusion),
+			Output:     output,
+		}); err != nil {
+			return err
+		}
+		a.logger.Infof("update '%s' check run of %s to %s", checkName, sha, conclusion)
+
+		return nil
+	}
+
+	if _, _, err := a.ghClient.Checks.CreateCheckRun(ctx, owner, repoName, github.CreateCheckRunOptions{
+		Name:       checkName,
+		HeadSHA:    sha,
+		Status:     github.Ptr(completedStatus),
+		Conclusion: github.Ptr(conclusion),
+		Output:     output,
+	}); err != nil {
+		return err
+	}
+	a.logger.Infof("create '%s' check run of %s with %s", checkName, sha, conclusion)
+
+	return nil
+}
+
+func (a *actor) Capture(ctx context.Context, event actors.GenericEvent) bool {
+	genericEvent := event.Event
+	if prEvent, ok := genericEvent.(github.PullRequestEvent); ok {
+		return a.capturePullRequest(prEvent)
+	}
+
+	commentEvent, ok := genericEvent.(github.IssueCommentEvent)
+	if !ok {
+		a.logger.Error("cannot extract event to github.IssueCommentEvent, please check event type")
+		return false
+	}
+
+	if !commentEvent.Issue.IsPullRequest() || len(commentEvent.Comment.GetBody()) == 0 {
+		return false
+	}
+	if commentEvent.Issue.GetClosedBy() != nil || !commentEvent.Issue.GetClosedAt().IsZero() {
+		return false
+	}
+
+	cancel, ok := commands.MatchInstruction(event.Commands, holdCommand)
+	if !ok {
+		return false
+	}
+	a.event = commentEvent
+	a.cancel = cancel
+
+	return true
+}
+
+// capturePullRequest keeps the check run in sync with the label when new commits are pushed,
+// or when the label is changed by hand. Nothing is done when the check run is disabled.
+func (a *actor) capturePullRequest(prEvent github.PullRequestEvent) bool {
+	if len(a.cfg.Hold.CheckName) == 0 {
+		return false
+	}
+
+	switch prEvent.GetAction() {
+	case openedAction, reopenedAction, synchronizeAction:
+	case labeledAction, unlabeledAction:
+		if prEvent.GetLabel().GetName() != a.cfg.Labels.Hold {
+			return false
+		}
+	default:
+		return false
+	}
+	a.prEvent = &prEvent
+
+	return true
+}
+
+func (a *actor) Name() string {
+	return holdActorName
+}
+
+func (a *actor) Commands() []string {
+	return []string{holdCommand}
+}
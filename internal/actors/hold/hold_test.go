@@ -0,0 +1,253 @@
+package hold
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/gookit/slog"
+	"github.com/gookit/slog/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
+)
+
+func TestHoldCapture(t *testing.T) {
+	cases := []struct {
+		caseName  string
+		event     any
+		body      string
+		checkName string
+		expect    bool
+		cancel    bool
+	}{
+		{
+			caseName: "hold actor capture hold instruction",
+			event:    newCommentEvent("/hold"),
+			body:     "/hold",
+			expect:   true,
+		},
+		{
+			caseName: "hold actor capture hold cancel instruction",
+			event:    newCommentEvent("/hold cancel"),
+			body:     "/hold cancel",
+			expect:   true,
+			cancel:   true,
+		},
+		{
+			caseName: "hold actor does not capture unknown argument",
+			event:    newCommentEvent("/hold on"),
+			body:     "/hold on",
+			expect:   false,
+		},
+		{
+			caseName: "hold actor does not capture issue that are not pull request",
+			event: github.IssueCommentEvent{
+				Issue:   &github.Issue{},
+				Comment: &github.IssueComment{Body: github.Ptr("/hold")},
+			},
+			body:   "/hold",
+			expect: false,
+		},
+		{
+			caseName: "hold actor does not capture closed pull request",
+			event: github.IssueCommentEvent{
+				Issue: &github.Issue{
+					PullRequestLinks: &github.PullRequestLinks{},
+					ClosedAt:         &github.Timestamp{Time: time.Now()},
+				},
+				Comment: &github.IssueComment{Body: github.Ptr("/hold")},
+			},
+			body:   "/hold",
+			expect: false,
+		},
+		{
+			caseName: "hold actor does not capture new commits when the check run is disabled",
+			event:    github.PullRequestEvent{Action: github.Ptr("synchronize")},
+			expect:   false,
+		},
+		{
+			caseName:  "hold actor capture new commits",
+			event:     github.PullRequestEvent{Action: github.Ptr("synchronize")},
+			checkName: "hold",
+			expect:    true,
+		},
+		{
+			caseName: "hold actor capture hold label changed by hand",
+			event: github.PullRequestEvent{
+				Action: github.Ptr("unlabeled"),
+				Label:  &github.Label{Name: github.Ptr("do-not-merge/hold")},
+			},
+			checkName: "hold",
+			expect:    true,
+		},
+		{
+			caseName: "hold actor does not capture other labels",
+			event: github.PullRequestEvent{
+				Action: github.Ptr("labeled"),
+				Label:  &github.Label{Name: github.Ptr("lgtm")},
+			},
+			checkName: "hold",
+			expect:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Hold.CheckName = tc.checkName
+			holdActor := &actor{cfg: cfg, logger: newLogger()}
+
+			event := actors.GenericEvent{Event: tc.event, Commands: commands.Parse(tc.body)}
+			assert.Equal(t, tc.expect, holdActor.Capture(context.Background(), event))
+			assert.Equal(t, tc.cancel, holdActor.cancel)
+		})
+	}
+}
+
+func TestHoldHandler(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		event       any
+		body        string
+		checkName   string
+		issueLabels []string
+		checkRuns   []*github.CheckRun
+
+		expectLabels     []string
+		expectReactions  map[int64][]string
+		expectMutations  []string
+		expectConclusion string
+	}{
+		{
+			caseName:        "hold adds the label",
+			event:           newCommentEvent("/hold"),
+			body:            "/hold",
+			expectLabels:    []string{"do-not-merge/hold"},
+			expectReactions: map[int64][]string{10: {"+1"}},
+			expectMutations: []string{
+				"POST /repos/foo/bar/issues/1/labels",
+				"POST /repos/foo/bar/issues/comments/10/reactions",
+			},
+		},
+		{
+			caseName:        "hold fails the check run",
+			event:           newCommentEvent("/hold"),
+			body:            "/hold",
+			checkName:       "hold",
+			expectLabels:    []string{"do-not-merge/hold"},
+			expectReactions: map[int64][]string{10: {"+1"}},
+			expectMutations: []string{
+				"POST /repos/foo/bar/issues/1/labels",
+				"POST /repos/foo/bar/issues/comments/10/reactions",
+				"POST /repos/foo/bar/check-runs",
+			},
+			expectConclusion: "failure",
+		},
+		{
+			caseName:        "hold cancel removes the label and passes the existing check run",
+			event:           newCommentEvent("/hold cancel"),
+			body:            "/hold cancel",
+			checkName:       "hold",
+			issueLabels:     []string{"do-not-merge/hold"},
+			checkRuns:       []*github.CheckRun{{ID: github.Ptr[int64](7), Name: github.Ptr("hold"), Conclusion: github.Ptr("failure")}},
+			expectReactions: map[int64][]string{10: {"+1"}},
+			expectMutations: []string{
+				"DELETE /repos/foo/bar/issues/1/labels/do-not-merge/hold",
+				"POST /repos/foo/bar/issues/comments/10/reactions",
+				"PATCH /repos/foo/bar/check-runs/7",
+			},
+			expectConclusion: "success",
+		},
+		{
+			caseName: "new commits of a held pull request fail the check run",
+			event: github.PullRequestEvent{
+				Action: github.Ptr("synchronize"),
+				Repo:   &github.Repository{FullName: github.Ptr("foo/bar")},
+				PullRequest: &github.PullRequest{
+					Number: github.Ptr(1),
+					Head:   &github.PullRequestBranch{SHA: github.Ptr("abc")},
+					Labels: []*github.Label{{Name: github.Ptr("do-not-merge/hold")}},
+				},
+			},
+			checkName: "hold",
+			// a check run of another app with another name is left alone
+			checkRuns:    []*github.CheckRun{{ID: github.Ptr[int64](7), Name: github.Ptr("build"), Conclusion: github.Ptr("success")}},
+			issueLabels:  []string{"do-not-merge/hold"},
+			expectLabels: []string{"do-not-merge/hold"},
+			expectMutations: []string{
+				"POST /repos/foo/bar/check-runs",
+			},
+			expectConclusion: "failure",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := githubtest.NewServer(t)
+			repo := server.Repo("foo/bar")
+			issue := repo.AddPullRequest(1, "alice", "abc")
+			issue.Labels = tc.issueLabels
+			repo.CheckRuns["abc"] = tc.checkRuns
+
+			cfg := config.Default()
+			cfg.Hold.CheckName = tc.checkName
+			holdActor := NewHoldActor(server.Client(), newLogger(), cfg)
+			event := actors.GenericEvent{Event: tc.event, Commands: commands.Parse(tc.body)}
+			require.True(t, holdActor.Capture(context.Background(), event))
+			require.NoError(t, holdActor.Handler(context.Background()))
+
+			assert.ElementsMatch(t, tc.expectLabels, issue.Labels)
+			assert.Equal(t, tc.expectMutations, server.Mutations())
+			if tc.expectReactions == nil {
+				assert.Empty(t, repo.Reactions)
+			} else {
+				assert.Equal(t, tc.expectReactions, repo.Reactions)
+			}
+
+			var holdRuns []*github.CheckRun
+			for _, run := range repo.CheckRuns["abc"] {
+				if run.GetName() == "hold" {
+					holdRuns = append(holdRuns, run)
+				}
+			}
+			if len(tc.expectConclusion) == 0 {
+				assert.Empty(t, holdRuns)
+				return
+			}
+			require.Len(t, holdRuns, 1)
+			assert.Equal(t, "completed", holdRuns[0].GetStatus())
+			assert.Equal(t, tc.expectConclusion, holdRuns[0].GetConclusion())
+		})
+	}
+}
+
+// newCommentEvent returns a comment of bob on the pull request #1 of alice
+func newCommentEvent(body string) github.IssueCommentEvent {
+	return github.IssueCommentEvent{
+		Repo: &github.Repository{FullName: github.Ptr("foo/bar")},
+		Issue: &github.Issue{
+			Number:           github.Ptr(1),
+			User:             &github.User{Login: github.Ptr("alice")},
+			PullRequestLinks: &github.PullRequestLinks{},
+		},
+		Comment: &github.IssueComment{
+			ID:   github.Ptr[int64](10),
+			User: &github.User{Login: github.Ptr("bob")},
+			Body: github.Ptr(body),
+		},
+	}
+}
+
+// newLogger returns a noop logger for testing only
+func newLogger() *slog.Logger {
+	return slog.NewWithConfig(func(l *slog.Logger) {
+		l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+	})
+}
@@ -2,8 +2,7 @@ package label
 
 import (
 	"context"
-	"fmt"
-	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/google/go-github/v72/github"
@@ -11,23 +10,26 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/permission"
 )
 
 const (
-	labelActorName = "LabelActor"
+	labelActorName = "label"
 
-	labelPrefix   = "/label"
-	unlabelPrefix = "/unlabel"
-)
+	labelCommand   = "label"
+	unlabelCommand = "unlabel"
 
-var (
-	labelRegex   = regexp.MustCompile(`(?m)^\s*/label\s*(.*?)\s*$`)
-	unlabelRegex = regexp.MustCompile(`(?m)^\s*/unlabel\s*(.*?)\s*$`)
+	lgtmCommand    = "lgtm"
+	approveCommand = "approve"
+	holdCommand    = "hold"
 )
 
 type actor struct {
 	ghClient *github.Client
 	logger   *slog.Logger
+	cfg      *config.Config
 
 	addLabels    []string
 	removeLabels []string
@@ -35,14 +37,15 @@ type actor struct {
 	event github.IssueCommentEvent
 }
 
-func NewLabelActor(ghClient *github.Client, logger *slog.Logger) actors.Actor {
+func NewLabelActor(ghClient *github.Client, logger *slog.Logger, cfg *config.Config) actors.Actor {
 	return &actor{
 		ghClient: ghClient,
 		logger:   logger,
+		cfg:      cfg,
 	}
 }
 
-func (a *actor) Handler() error {
+func (a *actor) Handler(ctx context.Context) error {
 	var (
 		issue           = a.event.GetIssue()
 		repo            = a.event.GetRepo()
@@ -53,12 +56,9 @@ func (a *actor) Handler() error {
 	a.logger.Infof("actor %s started processing events, pr number: #%d", a.Name(), issue.GetNumber())
 
 	repoLabels := sets.Set[string]{}
-	listLabels, _, err := a.ghClient.Issues.ListLabels(
-		context.Background(),
-		owner,
-		repoName,
-		nil,
-	)
+	listLabels, err := actors.ListAll(func(opts github.ListOptions) ([]*github.Label, *github.Response, error) {
+		return a.ghClient.Issues.ListLabels(ctx, owner, repoName, &opts)
+	})
 	if err != nil {
 		return err
 	}
@@ -75,8 +75,30 @@ func (a *actor) Handler() error {
 		}
 	}
 
-	var nonExistRepoLabels []string
+	var (
+		checker             = permission.NewChecker(a.ghClient, repo.GetFullName())
+		managedLabels       []string
+		managedCommands     []string
+		restrictedLabels    []string
+		nonExistRepoLabels  []string
+		nonExistIssueLabels []string
+	)
 	for _, addLabel := range a.addLabels {
+		if command, ok := a.managedCommand(addLabel); ok {
+			managedLabels = append(managedLabels, addLabel)
+			managedCommands = append(managedCommands, "/"+command)
+			continue
+		}
+
+		allowed, err := a.allowed(ctx, checker, loginUser.GetLogin(), addLabel)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			restrictedLabels = append(restrictedLabels, addLabel)
+			continue
+		}
+
 		if issueLabels.Has(addLabel) {
 			continue
 		}
@@ -87,14 +109,62 @@ func (a *actor) Handler() error {
 			continue
 		}
 
-		if err := actors.AddLabelToIssue(a.ghClient, repo.GetFullName(), issue.GetNumber(), addLabel); err != nil {
+		// the new label replaces the other labels of an exclusive namespace, so the user must be allowed to remove them as well
+		siblings := a.exclusiveSiblings(addLabel, issueLabels)
+		refused := false
+		for _, other := range siblings {
+			if command, ok := a.managedCommand(other); ok {
+				managedLabels = append(managedLabels, other)
+				managedCommands = append(managedCommands, "/"+command+" "+commands.CancelArg)
+				refused = true
+				continue
+			}
+
+			allowed, err := a.allowed(ctx, checker, loginUser.GetLogin(), other)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				restrictedLabels = append(restrictedLabels, other)
+				refused = true
+			}
+		}
+		if refused {
+			continue
+		}
+
+		if err := actors.AddLabelToIssue(ctx, a.ghClient, repo.GetFullName(), issue.GetNumber(), addLabel); err != nil {
 			a.logger.Errorf("actor %s failed to add '%s' label to actor %s: %v", a.Name(), labelActorName, addLabel, err)
 			return err
 		}
+		issueLabels.Insert(addLabel)
+
+		for _, other := range siblings {
+			if err := actors.RemoveLabelToIssue(ctx, a.ghClient, repo.GetFullName(), issue.GetNumber(), other); err != nil {
+				a.logger.Errorf("actor %s failed to remove '%s' label to actor %s: %v", a.Name(), labelActorName, other, err)
+				return err
+			}
+			issueLabels.Delete(other)
+			a.logger.Infof("remove '%s' label from issue #%d since it is replaced by '%s'", other, issue.GetNumber(), addLabel)
+		}
 	}
 
-	var nonExistIssueLabels []string
 	for _, removeLabel := range a.removeLabels {
+		if command, ok := a.managedCommand(removeLabel); ok {
+			managedLabels = append(managedLabels, removeLabel)
+			managedCommands = append(managedCommands, "/"+command+" "+commands.CancelArg)
+			continue
+		}
+
+		allowed, err := a.allowed(ctx, checker, loginUser.GetLogin(), removeLabel)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			restrictedLabels = append(restrictedLabels, removeLabel)
+			continue
+		}
+
 		// current issue does not have a label, we need to record the event
 		if !issueLabels.Has(removeLabel) {
 			nonExistIssueLabels = append(nonExistIssueLabels, removeLabel)
@@ -105,27 +175,54 @@ func (a *actor) Handler() error {
 			continue
 		}
 
-		if err := actors.RemoveLabelToIssue(a.ghClient, repo.GetFullName(), issue.GetNumber(), removeLabel); err != nil {
+		if err := actors.RemoveLabelToIssue(ctx, a.ghClient, repo.GetFullName(), issue.GetNumber(), removeLabel); err != nil {
 			a.logger.Errorf("actor %s failed to remove '%s' label to actor %s: %v", a.Name(), labelActorName, removeLabel, err)
 			return err
 		}
+		issueLabels.Delete(removeLabel)
 	}
 
 	switch {
+	case len(managedLabels) > 0:
+		a.logger.Warnf("user '%s' tried to change the labels '(%s)' maintained by their own commands", loginUser.GetLogin(), strings.Join(managedLabels, ","))
+		reply, err := config.RenderReply(a.cfg.Replies.LabelsManaged, config.ReplyData{
+			User:     loginUser.GetLogin(),
+			Labels:   strings.Join(managedLabels, ","),
+			Commands: strings.Join(managedCommands, ","),
+		})
+		if err != nil {
+			return err
+		}
+		return actors.AddComment(ctx, a.ghClient, reply, repo.GetFullName(), issue.GetNumber())
+
+	case len(restrictedLabels) > 0:
+		a.logger.Warnf("user '%s' is not allowed to change the restricted labels '(%s)'", loginUser.GetLogin(), strings.Join(restrictedLabels, ","))
+		reply, err := config.RenderReply(a.cfg.Replies.LabelsRestricted, config.ReplyData{User: loginUser.GetLogin(), Labels: strings.Join(restrictedLabels, ",")})
+		if err != nil {
+			return err
+		}
+		return actors.AddComment(ctx, a.ghClient, reply, repo.GetFullName(), issue.GetNumber())
+
 	case len(nonExistRepoLabels) > 0:
 		a.logger.Warnf("The repo is missing labels '(%s)'", strings.Join(nonExistRepoLabels, ","))
-		returnMsg := fmt.Sprintf("These labels '(%s)' cannot be used because they are not configured in the repo.", strings.Join(nonExistRepoLabels, ","))
-		return actors.AddComment(a.ghClient, fmt.Sprintf("@%s %s", loginUser.GetLogin(), returnMsg), repo.GetFullName(), issue.GetNumber())
+		reply, err := config.RenderReply(a.cfg.Replies.LabelsNotConfigured, config.ReplyData{User: loginUser.GetLogin(), Labels: strings.Join(nonExistRepoLabels, ",")})
+		if err != nil {
+			return err
+		}
+		return actors.AddComment(ctx, a.ghClient, reply, repo.GetFullName(), issue.GetNumber())
 
 	case len(nonExistIssueLabels) > 0:
-		returnMsg := fmt.Sprintf("These labels '(%s)' cannot be applied to issues because they are not exist in the issue.", strings.Join(nonExistIssueLabels, ","))
-		return actors.AddComment(a.ghClient, fmt.Sprintf("@%s %s", loginUser.GetLogin(), returnMsg), repo.GetFullName(), issue.GetNumber())
+		reply, err := config.RenderReply(a.cfg.Replies.LabelsNotExist, config.ReplyData{User: loginUser.GetLogin(), Labels: strings.Join(nonExistIssueLabels, ",")})
+		if err != nil {
+			return err
+		}
+		return actors.AddComment(ctx, a.ghClient, reply, repo.GetFullName(), issue.GetNumber())
 	default:
 		return nil
 	}
 }
 
-func (a *actor) Capture(event actors.GenericEvent) bool {
+func (a *actor) Capture(ctx context.Context, event actors.GenericEvent) bool {
 	genericEvent := event.Event
 	commentEvent, ok := genericEvent.(github.IssueCommentEvent)
 	if !ok {
@@ -133,12 +230,7 @@ func (a *actor) Capture(event actors.GenericEvent) bool {
 		return false
 	}
 
-	// pull request is essentially an issue, and the current actor does not handle this situation.
-	if commentEvent.Issue.IsPullRequest() {
-		return false
-	}
-
-	// do not handle closed issues
+	// do not handle closed issues and pull requests
 	if !commentEvent.Issue.GetClosedAt().IsZero() || commentEvent.Issue.ClosedBy != nil {
 		return false
 	}
@@ -148,33 +240,25 @@ func (a *actor) Capture(event actors.GenericEvent) bool {
 		return false
 	}
 
-	labelMatchers := labelRegex.FindAllStringSubmatch(comment.GetBody(), -1)
-	unlabelMatchers := unlabelRegex.FindAllStringSubmatch(comment.GetBody(), -1)
-	if len(labelMatchers) == 0 && len(unlabelMatchers) == 0 {
-		return false
-	}
-
-	// refine label matching rules
-	validateMatchFn := func(matches [][]string, prefix string) []string {
-		var ret []string
-		for _, match := range matches {
-			matchParts := strings.Split(
-				strings.TrimSpace(match[0]),
-				" ",
-			)
-			if len(matchParts) < 2 || matchParts[0] != prefix {
+	var addLabels, removeLabels []string
+	for _, cmd := range commands.Filter(event.Commands, a.Commands()...) {
+		// each argument is a label, labels containing spaces are quoted, e.g. /label kind/bug "help wanted"
+		for _, arg := range cmd.Args {
+			if len(strings.TrimSpace(arg)) == 0 {
 				continue
 			}
 
-			// reason for using match instead of matchParts is that some labels may contain spaces.
-			// e.g. "help wanted", "good first issue"...
-			ret = append(ret, match[1])
+			switch cmd.Name {
+			case labelCommand:
+				addLabels = append(addLabels, arg)
+			case unlabelCommand:
+				removeLabels = append(removeLabels, arg)
+			default:
+				// a shortcut adds a label of its namespace, e.g. "/kind bug" adds "kind/bug"
+				addLabels = append(addLabels, cmd.Name+"/"+arg)
+			}
 		}
-		return ret
 	}
-
-	addLabels := validateMatchFn(labelMatchers, labelPrefix)
-	removeLabels := validateMatchFn(unlabelMatchers, unlabelPrefix)
 	if len(addLabels) == 0 && len(removeLabels) == 0 {
 		return false
 	}
@@ -189,3 +273,52 @@ func (a *actor) Capture(event actors.GenericEvent) bool {
 func (a *actor) Name() string {
 	return labelActorName
 }
+
+// Commands the shortcuts of the configuration are commands as well
+func (a *actor) Commands() []string {
+	return append([]string{labelCommand, unlabelCommand}, a.cfg.Label.Shortcuts...)
+}
+
+// managedCommand returns the command maintaining the label when it is one of the labels maintained by other actors.
+// Such labels cannot be changed by /label and /unlabel, since that would bypass the checks of their own commands,
+// e.g. the author giving lgtm to their own pull request or approving it without the OWNERS.
+func (a *actor) managedCommand(label string) (string, bool) {
+	for _, managed := range []struct{ label, command string }{
+		{label: a.cfg.Labels.LGTM, command: lgtmCommand},
+		{label: a.cfg.Labels.Approved, command: approveCommand},
+		{label: a.cfg.Labels.Hold, command: holdCommand},
+	} {
+		if strings.EqualFold(label, managed.label) {
+			return managed.command, true
+		}
+	}
+
+	return "", false
+}
+
+// allowed reports whether the user fulfills the restriction of the label, labels without restriction can be changed by anyone
+func (a *actor) allowed(ctx context.Context, checker *permission.Checker, login, label string) (bool, error) {
+	restriction := a.cfg.Label.Restriction(label)
+	if restriction.IsEmpty() {
+		return true, nil
+	}
+
+	return checker.Allowed(ctx, login, restriction)
+}
+
+// exclusiveSiblings returns the other labels of the issue within the namespace of the label when the namespace is exclusive
+func (a *actor) exclusiveSiblings(label string, issueLabels sets.Set[string]) []string {
+	namespace, _, ok := strings.Cut(label, "/")
+	if !ok || !slices.Contains(a.cfg.Label.Exclusive, namespace) {
+		return nil
+	}
+
+	var siblings []string
+	for _, other := range sets.List(issueLabels) {
+		if other != label && strings.HasPrefix(other, namespace+"/") {
+			siblings = append(siblings, other)
+		}
+	}
+
+	return siblings
+}
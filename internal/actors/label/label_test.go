@@ -1,7 +1,12 @@
 package label
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -9,8 +14,12 @@ import (
 	"github.com/gookit/slog"
 	"github.com/gookit/slog/handler"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
 )
 
 func TestLabelCapture(t *testing.T) {
@@ -35,11 +44,11 @@ func TestLabelCapture(t *testing.T) {
 			addLabels: []string{"kind/chore"},
 		},
 		{
-			caseName: "label actor capture and handle space split label add events",
+			caseName: "label actor capture and handle quoted label add events",
 			event: actors.GenericEvent{
 				Event: github.IssueCommentEvent{
 					Comment: &github.IssueComment{
-						Body: github.Ptr[string]("/label help wanted"),
+						Body: github.Ptr[string](`/label "help wanted"`),
 					},
 					Issue: &github.Issue{},
 				},
@@ -48,14 +57,13 @@ func TestLabelCapture(t *testing.T) {
 			addLabels: []string{"help wanted"},
 		},
 		{
-			caseName: "label actor capture and handle multi line space split label add events",
+			caseName: "label actor capture and handle multi line quoted label add events",
 			event: actors.GenericEvent{
 				Event: github.IssueCommentEvent{
 					Comment: &github.IssueComment{
-						Body: github.Ptr[string](`
-						/label help wanted
-						/label kind/chore
-						`,
+						Body: github.Ptr[string]("\n" +
+							`/label "help wanted"` + "\n" +
+							"/label kind/chore\n",
 						),
 					},
 					Issue: &github.Issue{},
@@ -78,11 +86,11 @@ func TestLabelCapture(t *testing.T) {
 			removeLabels: []string{"kind/chore"},
 		},
 		{
-			caseName: "label actor capture and handle space split label remove events",
+			caseName: "label actor capture and handle quoted label remove events",
 			event: actors.GenericEvent{
 				Event: github.IssueCommentEvent{
 					Comment: &github.IssueComment{
-						Body: github.Ptr[string]("/unlabel help wanted"),
+						Body: github.Ptr[string](`/unlabel "help wanted"`),
 					},
 					Issue: &github.Issue{},
 				},
@@ -91,13 +99,13 @@ func TestLabelCapture(t *testing.T) {
 			removeLabels: []string{"help wanted"},
 		},
 		{
-			caseName: "label actor capture and handle multi line space split label remove events",
+			caseName: "label actor capture and handle multi line quoted label remove events",
 			event: actors.GenericEvent{
 				Event: github.IssueCommentEvent{
 					Comment: &github.IssueComment{
 						Body: github.Ptr[string](
-							`/unlabel help wanted
-							/unlabel kind/chore`,
+							`/unlabel "help wanted"` + "\n" +
+								"/unlabel kind/chore",
 						),
 					},
 					Issue: &github.Issue{},
@@ -112,11 +120,11 @@ func TestLabelCapture(t *testing.T) {
 				Event: github.IssueCommentEvent{
 					Comment: &github.IssueComment{
 						Body: github.Ptr[string](
-							`
-							/label area/first
-							/label area/second		
-							/unlabel help wanted
-							/unlabel kind/chore`,
+							"\n" +
+								"/label area/first\n" +
+								"/label area/second\t\t\n" +
+								`/unlabel "help wanted"` + "\n" +
+								"/unlabel kind/chore",
 						),
 					},
 					Issue: &github.Issue{},
@@ -127,17 +135,69 @@ func TestLabelCapture(t *testing.T) {
 			removeLabels: []string{"help wanted", "kind/chore"},
 		},
 		{
-			caseName: "label actor does not capture pull request",
+			caseName: "label actor capture and handle shortcut label add events",
 			event: actors.GenericEvent{
 				Event: github.IssueCommentEvent{
 					Comment: &github.IssueComment{
-						Body: github.Ptr[string]("/label good first issue"),
+						Body: github.Ptr[string]("/kind bug feature\n/priority p1"),
+					},
+					Issue: &github.Issue{},
+				},
+			},
+			expect:    true,
+			addLabels: []string{"kind/bug", "kind/feature", "priority/p1"},
+		},
+		{
+			caseName: "label actor does not capture shortcut without arguments",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/area"),
+					},
+					Issue: &github.Issue{},
+				},
+			},
+			expect: false,
+		},
+		{
+			caseName: "label actor capture and handle multiple labels in one command",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string](`/label a b "needs docs"` + "\n" + `/unlabel c 'good first issue'`),
+					},
+					Issue: &github.Issue{},
+				},
+			},
+			expect:       true,
+			addLabels:    []string{"a", "b", "needs docs"},
+			removeLabels: []string{"c", "good first issue"},
+		},
+		{
+			caseName: "label actor capture pull request",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string](`/label "good first issue"`),
 					},
 					Issue: &github.Issue{
 						PullRequestLinks: &github.PullRequestLinks{},
 					},
 				},
 			},
+			expect:    true,
+			addLabels: []string{"good first issue"},
+		},
+		{
+			caseName: "label actor does not capture empty quoted label",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string](`/label ""`),
+					},
+					Issue: &github.Issue{},
+				},
+			},
 			expect: false,
 		},
 		{
@@ -171,14 +231,235 @@ func TestLabelCapture(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.caseName, func(t *testing.T) {
 			labelActor := &actor{
+				cfg: config.Default(),
 				// a noop logger for testing only
 				logger: slog.NewWithConfig(func(l *slog.Logger) {
 					l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
 				}),
 			}
-			assert.Equal(t, tc.expect, labelActor.Capture(tc.event))
+			event := tc.event
+			if commentEvent, ok := event.Event.(github.IssueCommentEvent); ok {
+				event.Commands = commands.Parse(commentEvent.GetComment().GetBody())
+			}
+			assert.Equal(t, tc.expect, labelActor.Capture(context.Background(), event))
 			assert.Equal(t, tc.addLabels, labelActor.addLabels)
 			assert.Equal(t, tc.removeLabels, labelActor.removeLabels)
 		})
 	}
 }
+
+func TestLabelHandlerPaginatedLabels(t *testing.T) {
+	var calls []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/foo/bar/labels", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		// the configured label is only listed on the second page
+		if r.URL.Query().Get("page") != "2" {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/foo/bar/labels?page=2&per_page=100>; rel="next"`, r.Host))
+			_, _ = fmt.Fprint(w, `[{"name":"bug"}]`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `[{"name":"kind/feature"}]`)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		_, _ = fmt.Fprint(w, `[]`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ghClient := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	ghClient.BaseURL = baseURL
+
+	labelActor := &actor{
+		ghClient: ghClient,
+		// a noop logger for testing only
+		logger: slog.NewWithConfig(func(l *slog.Logger) {
+			l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+		}),
+		cfg:       config.Default(),
+		addLabels: []string{"kind/feature"},
+		event: github.IssueCommentEvent{
+			Repo:    &github.Repository{FullName: github.Ptr("foo/bar")},
+			Issue:   &github.Issue{Number: github.Ptr(1)},
+			Comment: &github.IssueComment{User: &github.User{Login: github.Ptr("alice")}},
+		},
+	}
+	require.NoError(t, labelActor.Handler(context.Background()))
+
+	// the label is added instead of being reported as not configured
+	assert.Equal(t, []string{
+		"GET /repos/foo/bar/labels?per_page=100",
+		"GET /repos/foo/bar/labels?page=2&per_page=100",
+		"POST /repos/foo/bar/issues/1/labels",
+	}, calls)
+}
+
+func TestLabelHandler(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		body        string
+		issueLabels []string
+		pullRequest bool
+		restricted  map[string]config.Permission
+		exclusive   []string
+		roles       map[string]string
+
+		expectLabels   []string
+		expectComments []string
+	}{
+		{
+			caseName:     "add a label",
+			body:         "/label kind/bug",
+			expectLabels: []string{"kind/bug"},
+		},
+		{
+			caseName:     "add a quoted label containing spaces",
+			body:         `/label "help wanted"`,
+			expectLabels: []string{"help wanted"},
+		},
+		{
+			caseName:       "reply when the label is not configured in the repo",
+			body:           "/label kind/unknown",
+			expectComments: []string{"@bob These labels '(kind/unknown)' cannot be used because they are not configured in the repo."},
+		},
+		{
+			caseName:     "remove a label",
+			body:         "/unlabel kind/bug",
+			issueLabels:  []string{"kind/bug", "help wanted"},
+			expectLabels: []string{"help wanted"},
+		},
+		{
+			caseName:       "reply when the issue does not have the label",
+			body:           "/unlabel kind/bug",
+			expectComments: []string{"@bob These labels '(kind/bug)' cannot be applied to issues because they are not exist in the issue."},
+		},
+		{
+			caseName:     "add several labels to a pull request",
+			body:         `/label kind/bug "help wanted"`,
+			pullRequest:  true,
+			expectLabels: []string{"kind/bug", "help wanted"},
+		},
+		{
+			caseName:     "add labels through a shortcut",
+			body:         "/kind bug",
+			expectLabels: []string{"kind/bug"},
+		},
+		{
+			caseName:     "replace the label of an exclusive namespace",
+			body:         "/priority p0",
+			issueLabels:  []string{"priority/p2", "kind/bug"},
+			expectLabels: []string{"priority/p0", "kind/bug"},
+		},
+		{
+			caseName:     "keep the labels of a namespace which is not exclusive",
+			body:         "/area networking",
+			issueLabels:  []string{"area/storage"},
+			expectLabels: []string{"area/storage", "area/networking"},
+		},
+		{
+			caseName:       "reply when the user is not allowed to add a restricted label",
+			body:           "/priority p0",
+			restricted:     map[string]config.Permission{"priority/*": {Role: "write"}},
+			roles:          map[string]string{"bob": "triage"},
+			issueLabels:    []string{"priority/p2"},
+			expectLabels:   []string{"priority/p2"},
+			expectComments: []string{"@bob These labels '(priority/p0)' are restricted, you are not allowed to add or remove them"},
+		},
+		{
+			caseName:       "refuse to replace a restricted label of an exclusive namespace",
+			body:           "/priority p0",
+			restricted:     map[string]config.Permission{"priority/p2": {Role: "maintain"}},
+			issueLabels:    []string{"priority/p2", "kind/bug"},
+			expectLabels:   []string{"priority/p2", "kind/bug"},
+			expectComments: []string{"@bob These labels '(priority/p2)' are restricted, you are not allowed to add or remove them"},
+		},
+		{
+			caseName:       "refuse to replace a managed label of an exclusive namespace",
+			body:           "/label do-not-merge/wip",
+			exclusive:      []string{"do-not-merge"},
+			issueLabels:    []string{"do-not-merge/hold"},
+			expectLabels:   []string{"do-not-merge/hold"},
+			expectComments: []string{"@bob These labels '(do-not-merge/hold)' are maintained by their own commands, use '(/hold cancel)' instead"},
+		},
+		{
+			caseName:     "add a restricted label with the required role",
+			body:         "/priority p0",
+			restricted:   map[string]config.Permission{"priority/*": {Role: "write"}},
+			roles:        map[string]string{"bob": "maintain"},
+			issueLabels:  []string{"priority/p2"},
+			expectLabels: []string{"priority/p0"},
+		},
+		{
+			caseName:       "reply when the user is not allowed to remove a restricted label",
+			body:           "/label kind/bug\n/unlabel \"help wanted\"",
+			restricted:     map[string]config.Permission{"help wanted": {Users: []string{"alice"}}},
+			issueLabels:    []string{"help wanted"},
+			expectLabels:   []string{"help wanted", "kind/bug"},
+			expectComments: []string{"@bob These labels '(help wanted)' are restricted, you are not allowed to add or remove them"},
+		},
+		{
+			caseName:       "refuse to add the labels maintained by their own commands",
+			body:           "/label lgtm kind/bug approved",
+			pullRequest:    true,
+			expectLabels:   []string{"kind/bug"},
+			expectComments: []string{"@bob These labels '(lgtm,approved)' are maintained by their own commands, use '(/lgtm,/approve)' instead"},
+		},
+		{
+			caseName:       "refuse to remove the hold label even with the required role",
+			body:           "/unlabel do-not-merge/hold",
+			pullRequest:    true,
+			roles:          map[string]string{"bob": "admin"},
+			issueLabels:    []string{"do-not-merge/hold"},
+			expectLabels:   []string{"do-not-merge/hold"},
+			expectComments: []string{"@bob These labels '(do-not-merge/hold)' are maintained by their own commands, use '(/hold cancel)' instead"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := githubtest.NewServer(t)
+			repo := server.Repo("foo/bar")
+			repo.Labels = []string{"kind/bug", "help wanted", "priority/p0", "priority/p2", "area/storage", "area/networking", "lgtm", "approved", "do-not-merge/hold", "do-not-merge/wip"}
+			for login, role := range tc.roles {
+				repo.Permissions[login] = role
+			}
+			var issue *githubtest.Issue
+			if tc.pullRequest {
+				issue = repo.AddPullRequest(1, "alice", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+			} else {
+				issue = repo.AddIssue(1, "alice")
+			}
+			issue.Labels = tc.issueLabels
+
+			var eventLabels []*github.Label
+			for _, label := range tc.issueLabels {
+				eventLabels = append(eventLabels, &github.Label{Name: github.Ptr(label)})
+			}
+			commentEvent := github.IssueCommentEvent{
+				Repo:    &github.Repository{FullName: github.Ptr("foo/bar")},
+				Issue:   &github.Issue{Number: github.Ptr(1), Labels: eventLabels},
+				Comment: &github.IssueComment{ID: github.Ptr[int64](10), User: &github.User{Login: github.Ptr("bob")}, Body: github.Ptr(tc.body)},
+			}
+			if tc.pullRequest {
+				commentEvent.Issue.PullRequestLinks = &github.PullRequestLinks{}
+			}
+			event := actors.GenericEvent{Event: commentEvent, Commands: commands.Parse(tc.body)}
+			cfg := config.Default()
+			cfg.Label.Restricted = tc.restricted
+			if tc.exclusive != nil {
+				cfg.Label.Exclusive = tc.exclusive
+			}
+			labelActor := NewLabelActor(server.Client(), slog.NewWithConfig(func(l *slog.Logger) {
+				l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+			}), cfg)
+			require.True(t, labelActor.Capture(context.Background(), event))
+			require.NoError(t, labelActor.Handler(context.Background()))
+
+			assert.ElementsMatch(t, tc.expectLabels, issue.Labels)
+			assert.Equal(t, tc.expectComments, issue.CommentBodies())
+		})
+	}
+}
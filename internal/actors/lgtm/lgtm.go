@@ -0,0 +1,241 @@
+package lgtm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/gookit/slog"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+)
+
+const (
+	lgtmActorName = "lgtm"
+
+	synchronizeAction = "synchronize"
+	submittedAction   = "submitted"
+	createdAction     = "created"
+
+	openState             = "open"
+	approvedState         = "approved"
+	changesRequestedState = "changes_requested"
+
+	lgtmCommand = "lgtm"
+)
+
+type actor struct {
+	ghClient *github.Client
+	logger   *slog.Logger
+	cfg      *config.Config
+
+	instruction *instruction
+	prEvent     *github.PullRequestEvent
+}
+
+// instruction an lgtm instruction extracted from a comment or a review
+type instruction struct {
+	fullName string
+	number   int
+
+	// author login of the pull request author
+	author string
+	// user login of the user who gave the instruction
+	user string
+
+	cancel bool
+
+	// react adds a reaction to the instruction, it is nil when the source does not support reactions
+	react func(ctx context.Context, reaction string) error
+}
+
+func NewLGTMActor(ghClient *github.Client, logger *slog.Logger, cfg *config.Config) actors.Actor {
+	return &actor{
+		ghClient: ghClient,
+		logger:   logger,
+		cfg:      cfg,
+	}
+}
+
+func (a *actor) Handler(ctx context.Context) error {
+	if a.prEvent != nil {
+		return a.handleSynchronize(ctx)
+	}
+
+	ins := a.instruction
+	a.logger.Infof("actor %s started processing events, pr number: #%d", a.Name(), ins.number)
+
+	if ins.cancel {
+		if err := actors.RemoveLabelToIssue(ctx, a.ghClient, ins.fullName, ins.number, a.cfg.Labels.LGTM); err != nil {
+			return err
+		}
+		a.logger.Infof("remove '%s' label from pr #%d", a.cfg.Labels.LGTM, ins.number)
+
+		return nil
+	}
+
+	// the author of the pull request is not allowed to approve their own changes
+	if ins.user == ins.author {
+		reply, err := config.RenderReply(a.cfg.Replies.SelfLGTM, config.ReplyData{User: ins.user, Label: a.cfg.Labels.LGTM})
+		if err != nil {
+			return err
+		}
+		return actors.AddComment(ctx, a.ghClient, reply, ins.fullName, ins.number)
+	}
+
+	if err := actors.AddLabelToIssue(ctx, a.ghClient, ins.fullName, ins.number, a.cfg.Labels.LGTM); err != nil {
+		return err
+	}
+	a.logger.Infof("add '%s' label to pr #%d", a.cfg.Labels.LGTM, ins.number)
+
+	if ins.react == nil {
+		return nil
+	}
+	if err := ins.react(ctx, a.cfg.Reactions.Commend); err != nil {
+		return err
+	}
+	a.logger.Infof("add a reaction '%s' to the instruction of pr #%d", a.cfg.Reactions.Commend, ins.number)
+
+	return nil
+}
+
+// handleSynchronize drops the lgtm label once new commits have been pushed to the pull request,
+// so that a stale approval never applies to changes nobody has looked at.
+func (a *actor) handleSynchronize(ctx context.Context) error {
+	var (
+		pr   = a.prEvent.GetPullRequest()
+		repo = a.prEvent.GetRepo()
+	)
+	a.logger.Infof("actor %s started processing events, pr number: #%d", a.Name(), pr.GetNumber())
+
+	if err := actors.RemoveLabelToIssue(ctx, a.ghClient, repo.GetFullName(), pr.GetNumber(), a.cfg.Labels.LGTM); err != nil {
+		return err
+	}
+	a.logger.Infof("remove '%s' label from pr #%d since new commits were pushed", a.cfg.Labels.LGTM, pr.GetNumber())
+
+	reply, err := config.RenderReply(a.cfg.Replies.LGTMRemoved, config.ReplyData{Label: a.cfg.Labels.LGTM})
+	if err != nil {
+		return err
+	}
+	return actors.AddComment(ctx, a.ghClient, reply, repo.GetFullName(), pr.GetNumber())
+}
+
+func (a *actor) Capture(ctx context.Context, event actors.GenericEvent) bool {
+	switch evt := event.Event.(type) {
+	case github.IssueCommentEvent:
+		return a.captureComment(evt, event.Commands)
+	case github.PullRequestEvent:
+		return a.capturePullRequest(evt)
+	case github.PullRequestReviewEvent:
+		return a.captureReview(evt)
+	case github.PullRequestReviewCommentEvent:
+		return a.captureReviewComment(evt, event.Commands)
+	default:
+		a.logger.Error("cannot extract event to a supported github event, please check event type")
+		return false
+	}
+}
+
+func (a *actor) captureComment(commentEvent github.IssueCommentEvent, cmds []commands.Command) bool {
+	if !commentEvent.Issue.IsPullRequest() || len(commentEvent.Comment.GetBody()) == 0 {
+		return false
+	}
+	if commentEvent.Issue.GetClosedBy() != nil || !commentEvent.Issue.GetClosedAt().IsZero() {
+		return false
+	}
+
+	cancel, ok := commands.MatchInstruction(cmds, lgtmCommand)
+	if !ok {
+		return false
+	}
+
+	fullName := commentEvent.GetRepo().GetFullName()
+	a.instruction = &instruction{
+		fullName: fullName,
+		number:   commentEvent.GetIssue().GetNumber(),
+		author:   commentEvent.GetIssue().GetUser().GetLogin(),
+		user:     commentEvent.GetComment().GetUser().GetLogin(),
+		cancel:   cancel,
+		react: func(ctx context.Context, reaction string) error {
+			return actors.AddReaction(ctx, a.ghClient, reaction, fullName, commentEvent.GetComment().GetID())
+		},
+	}
+
+	return true
+}
+
+// captureReview treats an approving review as lgtm and a review requesting changes as lgtm cancel
+func (a *actor) captureReview(reviewEvent github.PullRequestReviewEvent) bool {
+	if reviewEvent.GetAction() != submittedAction || reviewEvent.GetPullRequest().GetState() != openState {
+		return false
+	}
+
+	var cancel bool
+	switch state := reviewEvent.GetReview().GetState(); {
+	case strings.EqualFold(state, approvedState):
+		cancel = false
+	case strings.EqualFold(state, changesRequestedState):
+		cancel = true
+	default:
+		return false
+	}
+
+	a.instruction = &instruction{
+		fullName: reviewEvent.GetRepo().GetFullName(),
+		number:   reviewEvent.GetPullRequest().GetNumber(),
+		author:   reviewEvent.GetPullRequest().GetUser().GetLogin(),
+		user:     reviewEvent.GetReview().GetUser().GetLogin(),
+		cancel:   cancel,
+	}
+
+	return true
+}
+
+func (a *actor) captureReviewComment(commentEvent github.PullRequestReviewCommentEvent, cmds []commands.Command) bool {
+	if commentEvent.GetAction() != createdAction || commentEvent.GetPullRequest().GetState() != openState {
+		return false
+	}
+
+	cancel, ok := commands.MatchInstruction(cmds, lgtmCommand)
+	if !ok {
+		return false
+	}
+
+	fullName := commentEvent.GetRepo().GetFullName()
+	a.instruction = &instruction{
+		fullName: fullName,
+		number:   commentEvent.GetPullRequest().GetNumber(),
+		author:   commentEvent.GetPullRequest().GetUser().GetLogin(),
+		user:     commentEvent.GetComment().GetUser().GetLogin(),
+		cancel:   cancel,
+		react: func(ctx context.Context, reaction string) error {
+			return actors.AddPullRequestCommentReaction(ctx, a.ghClient, reaction, fullName, commentEvent.GetComment().GetID())
+		},
+	}
+
+	return true
+}
+
+func (a *actor) capturePullRequest(prEvent github.PullRequestEvent) bool {
+	if prEvent.GetAction() != synchronizeAction {
+		return false
+	}
+
+	// nothing to do when the pull request has not been approved yet
+	if !actors.HasLabel(prEvent.GetPullRequest().Labels, a.cfg.Labels.LGTM) {
+		return false
+	}
+	a.prEvent = &prEvent
+
+	return true
+}
+
+func (a *actor) Name() string {
+	return lgtmActorName
+}
+
+func (a *actor) Commands() []string {
+	return []string{lgtmCommand}
+}
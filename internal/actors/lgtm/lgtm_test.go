@@ -0,0 +1,306 @@
+package lgtm
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/gookit/slog"
+	"github.com/gookit/slog/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
+)
+
+func TestLGTMCapture(t *testing.T) {
+	prLinks := &github.PullRequestLinks{
+		URL: github.Ptr("https://github.com/example_owner/example_repo/pull/1234567890"),
+	}
+
+	cases := []struct {
+		caseName    string
+		event       actors.GenericEvent
+		expect      bool
+		cancel      bool
+		instruction bool
+		synchronize bool
+	}{
+		{
+			caseName: "lgtm actor capture and handle lgtm events",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/lgtm"),
+					},
+					Issue: &github.Issue{
+						PullRequestLinks: prLinks,
+					},
+				},
+			},
+			expect:      true,
+			instruction: true,
+		},
+		{
+			caseName: "lgtm actor capture and handle lgtm cancel events",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/lgtm cancel"),
+					},
+					Issue: &github.Issue{
+						PullRequestLinks: prLinks,
+					},
+				},
+			},
+			expect:      true,
+			cancel:      true,
+			instruction: true,
+		},
+		{
+			caseName: "lgtm actor does not capture issue that are not pull request",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/lgtm"),
+					},
+					Issue: &github.Issue{},
+				},
+			},
+			expect: false,
+		},
+		{
+			caseName: "lgtm actor does not capture closed pull request",
+			event: actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Comment: &github.IssueComment{
+						Body: github.Ptr[string]("/lgtm"),
+					},
+					Issue: &github.Issue{
+						PullRequestLinks: prLinks,
+						ClosedAt:         &github.Timestamp{Time: time.Now()},
+					},
+				},
+			},
+			expect: false,
+		},
+		{
+			caseName: "lgtm actor capture synchronize events of approved pull request",
+			event: actors.GenericEvent{
+				Event: github.PullRequestEvent{
+					Action: github.Ptr("synchronize"),
+					PullRequest: &github.PullRequest{
+						Labels: []*github.Label{
+							{Name: github.Ptr(actors.LGTMLabel)},
+						},
+					},
+				},
+			},
+			expect:      true,
+			synchronize: true,
+		},
+		{
+			caseName: "lgtm actor does not capture synchronize events of unapproved pull request",
+			event: actors.GenericEvent{
+				Event: github.PullRequestEvent{
+					Action:      github.Ptr("synchronize"),
+					PullRequest: &github.PullRequest{},
+				},
+			},
+			expect: false,
+		},
+		{
+			caseName: "lgtm actor capture approving review",
+			event: actors.GenericEvent{
+				Event: github.PullRequestReviewEvent{
+					Action:      github.Ptr("submitted"),
+					Review:      &github.PullRequestReview{State: github.Ptr("approved")},
+					PullRequest: &github.PullRequest{State: github.Ptr("open")},
+				},
+			},
+			expect:      true,
+			instruction: true,
+		},
+		{
+			caseName: "lgtm actor capture review requesting changes as cancel",
+			event: actors.GenericEvent{
+				Event: github.PullRequestReviewEvent{
+					Action:      github.Ptr("submitted"),
+					Review:      &github.PullRequestReview{State: github.Ptr("changes_requested")},
+					PullRequest: &github.PullRequest{State: github.Ptr("open")},
+				},
+			},
+			expect:      true,
+			cancel:      true,
+			instruction: true,
+		},
+		{
+			caseName: "lgtm actor does not capture commented review",
+			event: actors.GenericEvent{
+				Event: github.PullRequestReviewEvent{
+					Action:      github.Ptr("submitted"),
+					Review:      &github.PullRequestReview{State: github.Ptr("commented")},
+					PullRequest: &github.PullRequest{State: github.Ptr("open")},
+				},
+			},
+			expect: false,
+		},
+		{
+			caseName: "lgtm actor capture lgtm in review comment",
+			event: actors.GenericEvent{
+				Event: github.PullRequestReviewCommentEvent{
+					Action:      github.Ptr("created"),
+					Comment:     &github.PullRequestComment{Body: github.Ptr("/lgtm")},
+					PullRequest: &github.PullRequest{State: github.Ptr("open")},
+				},
+			},
+			expect:      true,
+			instruction: true,
+		},
+		{
+			caseName: "lgtm actor does not capture review comment of closed pull request",
+			event: actors.GenericEvent{
+				Event: github.PullRequestReviewCommentEvent{
+					Action:      github.Ptr("created"),
+					Comment:     &github.PullRequestComment{Body: github.Ptr("/lgtm")},
+					PullRequest: &github.PullRequest{State: github.Ptr("closed")},
+				},
+			},
+			expect: false,
+		},
+		{
+			caseName: "lgtm actor does not capture other pull request events",
+			event: actors.GenericEvent{
+				Event: github.PullRequestEvent{
+					Action: github.Ptr("opened"),
+					PullRequest: &github.PullRequest{
+						Labels: []*github.Label{
+							{Name: github.Ptr(actors.LGTMLabel)},
+						},
+					},
+				},
+			},
+			expect: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			lgtmActor := &actor{
+				cfg: config.Default(),
+				// a noop logger for testing only
+				logger: slog.NewWithConfig(func(l *slog.Logger) {
+					l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+				}),
+			}
+			event := tc.event
+			switch evt := event.Event.(type) {
+			case github.IssueCommentEvent:
+				event.Commands = commands.Parse(evt.GetComment().GetBody())
+			case github.PullRequestReviewCommentEvent:
+				event.Commands = commands.Parse(evt.GetComment().GetBody())
+			}
+			assert.Equal(t, tc.expect, lgtmActor.Capture(context.Background(), event))
+			if lgtmActor.instruction != nil {
+				assert.Equal(t, tc.cancel, lgtmActor.instruction.cancel)
+			}
+			assert.Equal(t, tc.instruction, lgtmActor.instruction != nil)
+			assert.Equal(t, tc.synchronize, lgtmActor.prEvent != nil)
+		})
+	}
+}
+
+func TestLGTMHandler(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		event       any
+		body        string
+		issueLabels []string
+
+		expectLabels    []string
+		expectComments  []string
+		expectReactions map[int64][]string
+	}{
+		{
+			caseName:        "lgtm adds the label and commends the comment",
+			event:           newCommentEvent("bob", "/lgtm"),
+			body:            "/lgtm",
+			expectLabels:    []string{"lgtm"},
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName:       "lgtm of the author is rejected",
+			event:          newCommentEvent("alice", "/lgtm"),
+			body:           "/lgtm",
+			expectComments: []string{"@alice You cannot LGTM your own pull request"},
+		},
+		{
+			caseName:    "lgtm cancel removes the label",
+			event:       newCommentEvent("bob", "/lgtm cancel"),
+			body:        "/lgtm cancel",
+			issueLabels: []string{"lgtm", "kind/bug"},
+
+			expectLabels: []string{"kind/bug"},
+		},
+		{
+			caseName: "new commits remove the label",
+			event: github.PullRequestEvent{
+				Action: github.Ptr("synchronize"),
+				Repo:   &github.Repository{FullName: github.Ptr("foo/bar")},
+				PullRequest: &github.PullRequest{
+					Number: github.Ptr(1),
+					Labels: []*github.Label{{Name: github.Ptr("lgtm")}},
+				},
+			},
+			issueLabels: []string{"lgtm"},
+
+			expectComments: []string{"New changes are detected, the 'lgtm' label has been removed"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := githubtest.NewServer(t)
+			repo := server.Repo("foo/bar")
+			issue := repo.AddPullRequest(1, "alice", "abc")
+			issue.Labels = tc.issueLabels
+
+			lgtmActor := NewLGTMActor(server.Client(), slog.NewWithConfig(func(l *slog.Logger) {
+				l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+			}), config.Default())
+			event := actors.GenericEvent{Event: tc.event, Commands: commands.Parse(tc.body)}
+			require.True(t, lgtmActor.Capture(context.Background(), event))
+			require.NoError(t, lgtmActor.Handler(context.Background()))
+
+			assert.ElementsMatch(t, tc.expectLabels, issue.Labels)
+			assert.Equal(t, tc.expectComments, issue.CommentBodies())
+			if tc.expectReactions == nil {
+				assert.Empty(t, repo.Reactions)
+			} else {
+				assert.Equal(t, tc.expectReactions, repo.Reactions)
+			}
+		})
+	}
+}
+
+// newCommentEvent returns a comment of the user on the pull request #1 of alice
+func newCommentEvent(user, body string) github.IssueCommentEvent {
+	return github.IssueCommentEvent{
+		Repo: &github.Repository{FullName: github.Ptr("foo/bar")},
+		Issue: &github.Issue{
+			Number:           github.Ptr(1),
+			User:             &github.User{Login: github.Ptr("alice")},
+			PullRequestLinks: &github.PullRequestLinks{},
+		},
+		Comment: &github.IssueComment{
+			ID:   github.Ptr[int64](10),
+			User: &github.User{Login: github.Ptr(user)},
+			Body: github.Ptr(body),
+		},
+	}
+}
@@ -0,0 +1,153 @@
+package lifecycle
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/gookit/slog"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+)
+
+const (
+	lifecycleActorName = "lifecycle"
+
+	openState   = "open"
+	closedState = "closed"
+
+	completedReason  = "completed"
+	notPlannedReason = "not_planned"
+	reopenedReason   = "reopened"
+
+	closeCommand  = "close"
+	reopenCommand = "reopen"
+	notPlannedArg = "not-planned"
+)
+
+type actor struct {
+	ghClient *github.Client
+	logger   *slog.Logger
+	cfg      *config.Config
+
+	event       github.IssueCommentEvent
+	instruction *instruction
+}
+
+// instruction a close or reopen instruction extracted from a comment
+type instruction struct {
+	// state the state the issue is moved to
+	state string
+
+	// reason the state reason of the issue, pull requests do not have one
+	reason string
+}
+
+func NewLifecycleActor(ghClient *github.Client, logger *slog.Logger, cfg *config.Config) actors.Actor {
+	return &actor{
+		ghClient: ghClient,
+		logger:   logger,
+		cfg:      cfg,
+	}
+}
+
+func (a *actor) Handler(ctx context.Context) error {
+	var (
+		issue     = a.event.GetIssue()
+		comment   = a.event.GetComment()
+		fullName  = a.event.GetRepo().GetFullName()
+		loginUser = comment.GetUser().GetLogin()
+		ins       = a.instruction
+	)
+	a.logger.Infof("actor %s started processing events, issue number: #%d", a.Name(), issue.GetNumber())
+
+	req := &github.IssueRequest{State: github.Ptr(ins.state)}
+	if !issue.IsPullRequest() {
+		req.StateReason = github.Ptr(ins.reason)
+	}
+	owner, repoName := actors.GetOwnerRepo(fullName)
+	if _, _, err := a.ghClient.Issues.Edit(ctx, owner, repoName, issue.GetNumber(), req); err != nil {
+		return err
+	}
+	a.logger.Infof("issue #%d is %s by '%s'", issue.GetNumber(), ins.state, loginUser)
+
+	return actors.AddReaction(ctx, a.ghClient, a.cfg.Reactions.Commend, fullName, comment.GetID())
+}
+
+// Exempt the author and the assignees of the issue can close and reopen it,
+// the other users must fulfill the permission requirement of the actor.
+func (a *actor) Exempt() bool {
+	var (
+		issue = a.event.GetIssue()
+		login = a.event.GetComment().GetUser().GetLogin()
+	)
+	if strings.EqualFold(issue.GetUser().GetLogin(), login) {
+		return true
+	}
+	for _, assignee := range issue.Assignees {
+		if strings.EqualFold(assignee.GetLogin(), login) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Capture unlike the other actors, closed issues are captured as well since they are the ones to reopen
+func (a *actor) Capture(ctx context.Context, event actors.GenericEvent) bool {
+	genericEvent := event.Event
+	commentEvent, ok := genericEvent.(github.IssueCommentEvent)
+	if !ok {
+		a.logger.Error("cannot extract event to github.IssueCommentEvent, please check event type")
+		return false
+	}
+
+	if len(commentEvent.Comment.GetBody()) == 0 {
+		return false
+	}
+
+	// a merged pull request cannot be reopened, and it is closed already
+	if !commentEvent.GetIssue().GetPullRequestLinks().GetMergedAt().IsZero() {
+		return false
+	}
+
+	ins, ok := parseInstruction(event.Commands)
+	if !ok {
+		return false
+	}
+	// nothing to do when the issue is in the requested state already
+	if commentEvent.GetIssue().GetState() == ins.state {
+		return false
+	}
+	a.event = commentEvent
+	a.instruction = ins
+
+	return true
+}
+
+func (a *actor) Name() string {
+	return lifecycleActorName
+}
+
+func (a *actor) Commands() []string {
+	return []string{closeCommand, reopenCommand}
+}
+
+// parseInstruction extracts the close or reopen instruction of the commands, the last instruction wins
+func parseInstruction(cmds []commands.Command) (*instruction, bool) {
+	var ins *instruction
+	for _, cmd := range commands.Filter(cmds, closeCommand, reopenCommand) {
+		switch {
+		case cmd.Name == reopenCommand && len(cmd.Args) == 0:
+			ins = &instruction{state: openState, reason: reopenedReason}
+		case cmd.Name == closeCommand && len(cmd.Args) == 0:
+			ins = &instruction{state: closedState, reason: completedReason}
+		case cmd.Name == closeCommand && len(cmd.Args) == 1 && strings.EqualFold(cmd.Args[0], notPlannedArg):
+			ins = &instruction{state: closedState, reason: notPlannedReason}
+		}
+	}
+
+	return ins, ins != nil
+}
@@ -0,0 +1,281 @@
+package lifecycle
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/gookit/slog"
+	"github.com/gookit/slog/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
+)
+
+func TestParseInstruction(t *testing.T) {
+	cases := []struct {
+		caseName string
+		body     string
+		expect   *instruction
+	}{
+		{
+			caseName: "close",
+			body:     "/close",
+			expect:   &instruction{state: "closed", reason: "completed"},
+		},
+		{
+			caseName: "close as not planned",
+			body:     "/close Not-Planned",
+			expect:   &instruction{state: "closed", reason: "not_planned"},
+		},
+		{
+			caseName: "reopen",
+			body:     "/reopen",
+			expect:   &instruction{state: "open", reason: "reopened"},
+		},
+		{
+			caseName: "the last instruction wins",
+			body:     "/close\n/reopen",
+			expect:   &instruction{state: "open", reason: "reopened"},
+		},
+		{
+			caseName: "unknown reason is ignored",
+			body:     "/close duplicate",
+		},
+		{
+			caseName: "reopen takes no argument",
+			body:     "/reopen now",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			ins, ok := parseInstruction(commands.Parse(tc.body))
+			assert.Equal(t, tc.expect != nil, ok)
+			assert.Equal(t, tc.expect, ins)
+		})
+	}
+}
+
+func TestLifecycleCapture(t *testing.T) {
+	cases := []struct {
+		caseName string
+		body     string
+		state    string
+		merged   bool
+		expect   bool
+	}{
+		{
+			caseName: "lifecycle actor capture close of an open issue",
+			body:     "/close",
+			state:    "open",
+			expect:   true,
+		},
+		{
+			caseName: "lifecycle actor capture reopen of a closed issue",
+			body:     "/reopen",
+			state:    "closed",
+			expect:   true,
+		},
+		{
+			caseName: "lifecycle actor does not capture close of a closed issue",
+			body:     "/close",
+			state:    "closed",
+			expect:   false,
+		},
+		{
+			caseName: "lifecycle actor does not capture reopen of an open issue",
+			body:     "/reopen",
+			state:    "open",
+			expect:   false,
+		},
+		{
+			caseName: "lifecycle actor does not capture reopen of a merged pull request",
+			body:     "/reopen",
+			state:    "closed",
+			merged:   true,
+			expect:   false,
+		},
+		{
+			caseName: "lifecycle actor does not capture other commands",
+			body:     "/lgtm",
+			state:    "open",
+			expect:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			lifecycleActor := NewLifecycleActor(nil, newLogger(), config.Default())
+			commentEvent := newCommentEvent("bob", tc.body, tc.state, tc.merged)
+			if tc.merged {
+				commentEvent.Issue.PullRequestLinks.MergedAt = &github.Timestamp{Time: time.Now()}
+			}
+			event := actors.GenericEvent{
+				Event:    commentEvent,
+				Commands: commands.Parse(tc.body),
+			}
+			assert.Equal(t, tc.expect, lifecycleActor.Capture(context.Background(), event))
+		})
+	}
+}
+
+func TestLifecycleHandler(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		user        string
+		body        string
+		state       string
+		pullRequest bool
+		assignees   []string
+
+		expectState     string
+		expectReason    string
+		expectReactions map[int64][]string
+	}{
+		{
+			caseName:        "author closes the issue",
+			user:            "alice",
+			body:            "/close",
+			state:           "open",
+			expectState:     "closed",
+			expectReason:    "completed",
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName:        "assignee closes the issue as not planned",
+			user:            "bob",
+			body:            "/close not-planned",
+			state:           "open",
+			assignees:       []string{"bob"},
+			expectState:     "closed",
+			expectReason:    "not_planned",
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName:        "collaborator reopens the issue",
+			user:            "bob",
+			body:            "/reopen",
+			state:           "closed",
+			expectState:     "open",
+			expectReason:    "reopened",
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+		{
+			caseName:        "author closes the pull request without a reason",
+			user:            "alice",
+			body:            "/close not-planned",
+			state:           "open",
+			pullRequest:     true,
+			expectState:     "closed",
+			expectReactions: map[int64][]string{10: {"+1"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := githubtest.NewServer(t)
+			repo := server.Repo("foo/bar")
+			issue := repo.AddIssue(1, "alice")
+			if tc.pullRequest {
+				issue = repo.AddPullRequest(1, "alice", "abc")
+			}
+			issue.State = tc.state
+			issue.Assignees = tc.assignees
+
+			event := newCommentEvent(tc.user, tc.body, tc.state, tc.pullRequest)
+			for _, assignee := range tc.assignees {
+				event.Issue.Assignees = append(event.Issue.Assignees, &github.User{Login: github.Ptr(assignee)})
+			}
+			lifecycleActor := NewLifecycleActor(server.Client(), newLogger(), config.Default())
+			require.True(t, lifecycleActor.Capture(context.Background(), actors.GenericEvent{Event: event, Commands: commands.Parse(tc.body)}))
+			require.NoError(t, lifecycleActor.Handler(context.Background()))
+
+			assert.Equal(t, tc.expectState, issue.State)
+			assert.Equal(t, tc.expectReason, issue.StateReason)
+			assert.Empty(t, issue.CommentBodies())
+			if tc.expectReactions == nil {
+				assert.Empty(t, repo.Reactions)
+			} else {
+				assert.Equal(t, tc.expectReactions, repo.Reactions)
+			}
+		})
+	}
+}
+
+func TestLifecycleExempt(t *testing.T) {
+	cases := []struct {
+		caseName  string
+		user      string
+		assignees []string
+		expect    bool
+	}{
+		{
+			caseName: "author is exempted",
+			user:     "Alice",
+			expect:   true,
+		},
+		{
+			caseName:  "assignee is exempted",
+			user:      "bob",
+			assignees: []string{"bob"},
+			expect:    true,
+		},
+		{
+			caseName:  "other users must fulfill the requirement",
+			user:      "carol",
+			assignees: []string{"bob"},
+			expect:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			event := newCommentEvent(tc.user, "/close", "open", false)
+			for _, assignee := range tc.assignees {
+				event.Issue.Assignees = append(event.Issue.Assignees, &github.User{Login: github.Ptr(assignee)})
+			}
+			lifecycleActor := NewLifecycleActor(nil, newLogger(), config.Default())
+			require.True(t, lifecycleActor.Capture(context.Background(), actors.GenericEvent{Event: event, Commands: commands.Parse("/close")}))
+
+			exempter, ok := lifecycleActor.(actors.Exempter)
+			require.True(t, ok)
+			assert.Equal(t, tc.expect, exempter.Exempt())
+		})
+	}
+}
+
+// newCommentEvent returns a comment of the user on the issue #1 of alice
+func newCommentEvent(user, body, state string, pullRequest bool) github.IssueCommentEvent {
+	event := github.IssueCommentEvent{
+		Repo: &github.Repository{FullName: github.Ptr("foo/bar")},
+		Issue: &github.Issue{
+			Number: github.Ptr(1),
+			State:  github.Ptr(state),
+			User:   &github.User{Login: github.Ptr("alice")},
+		},
+		Comment: &github.IssueComment{
+			ID:   github.Ptr[int64](10),
+			User: &github.User{Login: github.Ptr(user)},
+			Body: github.Ptr(body),
+		},
+	}
+	if pullRequest {
+		event.Issue.PullRequestLinks = &github.PullRequestLinks{}
+	}
+
+	return event
+}
+
+// newLogger returns a noop logger for testing only
+func newLogger() *slog.Logger {
+	return slog.NewWithConfig(func(l *slog.Logger) {
+		l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+	})
+}
@@ -4,37 +4,68 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/google/go-github/v72/github"
 	"github.com/gookit/slog"
 	"github.com/hashicorp/go-multierror"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
 )
 
 const (
-	retestActorName = "AssignActor"
+	retestActorName = "retest"
 
-	failedConclusion = "failure"
-)
+	completedStatus = "completed"
+
+	retestCommand = "retest"
+	testCommand   = "test"
 
-var retestRegexp = regexp.MustCompile(`^/retest\s*$`)
+	allArg  = "all"
+	listArg = "?"
+)
 
 type actor struct {
 	ghClient *github.Client
 	logger   *slog.Logger
+	cfg      *config.Config
+
+	event       github.IssueCommentEvent
+	instruction *instruction
+}
+
+// instruction describes which check runs should be rerun
+type instruction struct {
+	// test whether the jobs are selected by name regardless of their conclusions,
+	// otherwise only the jobs with a rerunnable conclusion are selected
+	test bool
+
+	// patterns of /retest select rerunnable jobs, every rerunnable job is selected when it is empty.
+	// A pattern containing '*' or '?' is matched against the whole job name, otherwise it matches any part of it.
+	patterns []string
+
+	// jobs of /test select jobs by their names
+	jobs []string
+
+	// all selects every completed job
+	all bool
 
-	event github.IssueCommentEvent
+	// list replies with the jobs and their latest conclusions instead of rerunning any job
+	list bool
 }
 
-func NewRetestActor(ghClient *github.Client, logger *slog.Logger) actors.Actor {
+func NewRetestActor(ghClient *github.Client, logger *slog.Logger, cfg *config.Config) actors.Actor {
 	return &actor{
 		ghClient: ghClient,
 		logger:   logger,
+		cfg:      cfg,
 	}
 }
 
-func (a *actor) Handler() error {
+func (a *actor) Handler(ctx context.Context) error {
 	var (
 		issue           = a.event.GetIssue()
 		repo            = a.event.GetRepo()
@@ -44,70 +75,122 @@ func (a *actor) Handler() error {
 	)
 	a.logger.Infof("actor %s started processing events, pr number: #%d", a.Name(), issue.GetNumber())
 
-	pr, err := actors.GetPRFromIssue(a.ghClient, repo.GetFullName(), issue)
+	pr, err := actors.GetPRFromIssue(ctx, a.ghClient, repo.GetFullName(), issue)
 	if err != nil {
 		return err
 	}
 
-	checkRuns, _, err := a.ghClient.Checks.ListCheckRunsForRef(
-		context.Background(),
-		owner,
-		repoName,
-		pr.GetHead().GetSHA(),
-		nil,
-	)
+	runs, err := actors.ListAll(func(opts github.ListOptions) ([]*github.CheckRun, *github.Response, error) {
+		checkRuns, resp, err := a.ghClient.Checks.ListCheckRunsForRef(
+			ctx,
+			owner,
+			repoName,
+			pr.GetHead().GetSHA(),
+			&github.ListCheckRunsOptions{ListOptions: opts},
+		)
+		if err != nil {
+			return nil, resp, err
+		}
+		return checkRuns.CheckRuns, resp, nil
+	})
 	if err != nil {
 		return err
 	}
 
-	var failedRuns []*github.CheckRun
-	if checkRuns.CheckRuns == nil {
-		return nil
+	if a.instruction.list {
+		reply, err := config.RenderReply(a.cfg.Replies.JobsList, config.ReplyData{User: loginUser})
+		if err != nil {
+			return err
+		}
+		return actors.AddComment(ctx, a.ghClient, reply+"\n\n"+jobsTable(runs), repo.GetFullName(), issue.GetNumber())
 	}
-	for _, run := range checkRuns.CheckRuns {
-		if run.GetConclusion() == failedConclusion {
-			failedRuns = append(failedRuns, run)
+
+	selectedRuns, unknownJobs := selectRuns(a.instruction, runs, a.cfg.Retest.Conclusions)
+	if len(unknownJobs) != 0 {
+		reply, err := config.RenderReply(a.cfg.Replies.JobsNotFound, config.ReplyData{User: loginUser, Jobs: strings.Join(unknownJobs, ",")})
+		if err != nil {
+			return err
+		}
+		if err := actors.AddComment(ctx, a.ghClient, reply, repo.GetFullName(), issue.GetNumber()); err != nil {
+			return err
 		}
 	}
 
-	if len(failedRuns) == 0 {
-		if err := actors.AddComment(
-			a.ghClient,
-			fmt.Sprintf("@%s %s", loginUser, "The current checks run has all been run successfully and there is no need to rerun it again"),
-			repo.GetFullName(),
-			issue.GetNumber(),
-		); err != nil {
+	if len(selectedRuns) == 0 {
+		// the jobs which cannot be found have been reported already
+		if a.instruction.test {
+			return nil
+		}
+		reply, err := config.RenderReply(a.cfg.Replies.ChecksPassed, config.ReplyData{User: loginUser})
+		if err != nil {
 			return err
 		}
-	} else {
-		if err := actors.AddReaction(a.ghClient, actors.RocketReaction, repo.GetFullName(), comment.GetID()); err != nil {
-			a.logger.Errorf("failed to add reaction %s to #%d comment in #%d issue", actors.RocketReaction, issue.GetNumber(), comment.GetID())
-		}
-
-		errG := multierror.Append(nil)
-		for _, run := range failedRuns {
-			if _, err := a.ghClient.Actions.RerunJobByID(
-				context.Background(),
-				owner,
-				repoName,
-				run.GetID(),
-			); err != nil {
-				a.logger.Errorf("failed to rerun failed '%s' job by err: %v", run.GetName(), err)
-				errG = multierror.Append(errG, err)
-				continue
-			}
-			a.logger.Infof("success to rerun failed '%s' job", run.GetName())
+		return actors.AddComment(ctx, a.ghClient, reply, repo.GetFullName(), issue.GetNumber())
+	}
+
+	if err := actors.AddReaction(ctx, a.ghClient, a.cfg.Reactions.Rocket, repo.GetFullName(), comment.GetID()); err != nil {
+		a.logger.Errorf("failed to add reaction %s to #%d comment in #%d issue", a.cfg.Reactions.Rocket, issue.GetNumber(), comment.GetID())
+	}
+
+	// jobs named by /test may have passed, they are not covered by rerunning the failed jobs of a workflow run
+	if a.cfg.Retest.WorkflowRuns && !a.instruction.test {
+		return a.rerunWorkflowRuns(ctx, owner, repoName, selectedRuns)
+	}
+
+	return a.rerunJobs(ctx, owner, repoName, selectedRuns)
+}
+
+func (a *actor) rerunJobs(ctx context.Context, owner, repoName string, runs []*github.CheckRun) error {
+	errG := multierror.Append(nil)
+	for _, run := range runs {
+		if _, err := a.ghClient.Actions.RerunJobByID(
+			ctx,
+			owner,
+			repoName,
+			run.GetID(),
+		); err != nil {
+			a.logger.Errorf("failed to rerun '%s' job by err: %v", run.GetName(), err)
+			errG = multierror.Append(errG, err)
+			continue
+		}
+		a.logger.Infof("success to rerun '%s' job", run.GetName())
+	}
+
+	return errG.Unwrap()
+}
+
+// rerunWorkflowRuns reruns the failed jobs of the workflow runs owning the check runs,
+// each workflow run is rerun once no matter how many of its jobs have been selected.
+func (a *actor) rerunWorkflowRuns(ctx context.Context, owner, repoName string, runs []*github.CheckRun) error {
+	errG := multierror.Append(nil)
+
+	// the id of a check run created by GitHub Actions is the id of its job
+	var workflowRunIDs []int64
+	for _, run := range runs {
+		job, _, err := a.ghClient.Actions.GetWorkflowJobByID(ctx, owner, repoName, run.GetID())
+		if err != nil {
+			a.logger.Errorf("failed to get the workflow run of '%s' job by err: %v", run.GetName(), err)
+			errG = multierror.Append(errG, err)
+			continue
+		}
+		if !slices.Contains(workflowRunIDs, job.GetRunID()) {
+			workflowRunIDs = append(workflowRunIDs, job.GetRunID())
 		}
+	}
 
-		if errG.Unwrap() != nil {
-			return errG.Unwrap()
+	for _, runID := range workflowRunIDs {
+		if _, err := a.ghClient.Actions.RerunFailedJobsByID(ctx, owner, repoName, runID); err != nil {
+			a.logger.Errorf("failed to rerun the failed jobs of workflow run %d by err: %v", runID, err)
+			errG = multierror.Append(errG, err)
+			continue
 		}
+		a.logger.Infof("success to rerun the failed jobs of workflow run %d", runID)
 	}
 
-	return nil
+	return errG.Unwrap()
 }
 
-func (a *actor) Capture(event actors.GenericEvent) bool {
+func (a *actor) Capture(ctx context.Context, event actors.GenericEvent) bool {
 	genericEvent := event.Event
 	commentEvent, ok := genericEvent.(github.IssueCommentEvent)
 	if !ok {
@@ -122,10 +205,12 @@ func (a *actor) Capture(event actors.GenericEvent) bool {
 		return false
 	}
 
-	if !retestRegexp.MatchString(commentEvent.Comment.GetBody()) {
+	ins, ok := parseInstruction(event.Commands)
+	if !ok {
 		return false
 	}
 	a.event = commentEvent
+	a.instruction = ins
 
 	return true
 }
@@ -133,3 +218,113 @@ func (a *actor) Capture(event actors.GenericEvent) bool {
 func (a *actor) Name() string {
 	return retestActorName
 }
+
+func (a *actor) Commands() []string {
+	return []string{retestCommand, testCommand}
+}
+
+// parseInstruction extracts the retest or test instruction of the commands, the last instruction wins
+func parseInstruction(cmds []commands.Command) (*instruction, bool) {
+	var ins *instruction
+	for _, cmd := range commands.Filter(cmds, retestCommand, testCommand) {
+		switch {
+		case cmd.Name == retestCommand:
+			ins = &instruction{patterns: cmd.Args}
+		case len(cmd.Args) == 0:
+			// /test requires to name the jobs
+			continue
+		case len(cmd.Args) == 1 && cmd.Args[0] == listArg:
+			ins = &instruction{test: true, list: true}
+		case len(cmd.Args) == 1 && strings.EqualFold(cmd.Args[0], allArg):
+			ins = &instruction{test: true, all: true}
+		default:
+			ins = &instruction{test: true, jobs: cmd.Args}
+		}
+	}
+
+	return ins, ins != nil
+}
+
+// selectRuns returns the check runs selected by the instruction, /retest only selects the check runs
+// with one of the conclusions. The jobs named by the instruction which cannot be found are returned as well.
+func selectRuns(ins *instruction, runs []*github.CheckRun, conclusions []string) (selected []*github.CheckRun, unknown []string) {
+	switch {
+	case !ins.test:
+		for _, run := range runs {
+			if slices.Contains(conclusions, run.GetConclusion()) && matchAny(ins.patterns, run.GetName()) {
+				selected = append(selected, run)
+			}
+		}
+	case ins.all:
+		for _, run := range runs {
+			if run.GetStatus() == completedStatus {
+				selected = append(selected, run)
+			}
+		}
+	default:
+		for _, job := range ins.jobs {
+			found := false
+			for _, run := range runs {
+				if !strings.EqualFold(run.GetName(), job) {
+					continue
+				}
+				found = true
+				// a job which is still running cannot be rerun
+				if run.GetStatus() == completedStatus && !slices.Contains(selected, run) {
+					selected = append(selected, run)
+				}
+			}
+			if !found {
+				unknown = append(unknown, job)
+			}
+		}
+	}
+
+	return selected, unknown
+}
+
+// matchAny reports whether the job name matches any pattern, every name matches when there is no pattern
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if !strings.ContainsAny(pattern, "*?") {
+			if strings.Contains(name, pattern) {
+				return true
+			}
+			continue
+		}
+
+		// '*' matches any characters including '/', since job names are usually "workflow / job"
+		expr := strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern))
+		if matched, err := regexp.MatchString("^"+expr+"$", name); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jobsTable renders the check runs as a markdown table sorted by name
+func jobsTable(runs []*github.CheckRun) string {
+	sorted := slices.Clone(runs)
+	slices.SortFunc(sorted, func(x, y *github.CheckRun) int {
+		return strings.Compare(x.GetName(), y.GetName())
+	})
+
+	var sb strings.Builder
+	sb.WriteString("| Job | Status | Conclusion |\n|-----|--------|------------|\n")
+	for _, run := range sorted {
+		conclusion := run.GetConclusion()
+		if len(conclusion) == 0 {
+			conclusion = "-"
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s |\n", strings.ReplaceAll(run.GetName(), "|", "\\|"), run.GetStatus(), conclusion)
+	}
+
+	return sb.String()
+}
@@ -1,7 +1,12 @@
 package retest
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -9,40 +14,218 @@ import (
 	"github.com/gookit/slog"
 	"github.com/gookit/slog/handler"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
 )
 
-func TestRetestCommentBodyMatch(t *testing.T) {
+func TestParseInstruction(t *testing.T) {
 	cases := []struct {
 		caseName string
 		comment  string
-		expect   bool
+		expect   *instruction
 	}{
 		{
 			caseName: "Match the retest instruction",
 			comment:  "/retest",
-			expect:   true,
+			expect:   &instruction{},
 		},
 		{
 			caseName: "Match the instructions that show multiple spaces after retest",
 			comment:  "/retest    ",
-			expect:   true,
+			expect:   &instruction{},
+		},
+		{
+			caseName: "Match the retest instruction on any line",
+			comment:  "flaky test again\n/retest",
+			expect:   &instruction{},
+		},
+		{
+			caseName: "retest with name patterns",
+			comment:  "/retest lint e2e-*",
+			expect:   &instruction{patterns: []string{"lint", "e2e-*"}},
+		},
+		{
+			caseName: "test named jobs",
+			comment:  "/test lint \"unit test\"",
+			expect:   &instruction{test: true, jobs: []string{"lint", "unit test"}},
+		},
+		{
+			caseName: "test all jobs",
+			comment:  "/test all",
+			expect:   &instruction{test: true, all: true},
+		},
+		{
+			caseName: "list the jobs",
+			comment:  "/test ?",
+			expect:   &instruction{test: true, list: true},
+		},
+		{
+			caseName: "the last instruction wins",
+			comment:  "/test ?\n/retest",
+			expect:   &instruction{},
+		},
+		{
+			caseName: "test without job",
+			comment:  "/test",
 		},
 		{
 			caseName: "unmatched instructions",
 			comment:  "/redo",
-			expect:   false,
+		},
+		{
+			caseName: "unmatched instructions in code block",
+			comment:  "```\n/retest\n```",
 		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.caseName, func(t *testing.T) {
-			assert.Equal(t, tc.expect, retestRegexp.MatchString(tc.comment))
+			ins, ok := parseInstruction(commands.Parse(tc.comment))
+			assert.Equal(t, tc.expect != nil, ok)
+			assert.Equal(t, tc.expect, ins)
 		})
 	}
 }
 
+func TestSelectRuns(t *testing.T) {
+	run := func(name, status, conclusion string) *github.CheckRun {
+		return &github.CheckRun{Name: github.Ptr(name), Status: github.Ptr(status), Conclusion: github.Ptr(conclusion)}
+	}
+	var (
+		lint    = run("ci / lint", "completed", "failure")
+		unit    = run("ci / unit test", "completed", "success")
+		e2eA    = run("e2e-a", "completed", "failure")
+		e2eB    = run("e2e-b", "in_progress", "")
+		e2eC    = run("e2e-c", "completed", "timed_out")
+		runs    = []*github.CheckRun{lint, unit, e2eA, e2eB, e2eC}
+		nameSet = func(runs []*github.CheckRun) []string {
+			var names []string
+			for _, r := range runs {
+				names = append(names, r.GetName())
+			}
+			return names
+		}
+	)
+
+	cases := []struct {
+		caseName    string
+		ins         *instruction
+		conclusions []string
+		selected    []string
+		unknown     []string
+	}{
+		{
+			caseName: "retest every failed job",
+			ins:      &instruction{},
+			selected: []string{"ci / lint", "e2e-a", "e2e-c"},
+		},
+		{
+			caseName:    "retest only configured conclusions",
+			ins:         &instruction{},
+			conclusions: []string{"timed_out"},
+			selected:    []string{"e2e-c"},
+		},
+		{
+			caseName: "retest failed jobs containing the pattern",
+			ins:      &instruction{patterns: []string{"LINT"}},
+			selected: []string{"ci / lint"},
+		},
+		{
+			caseName: "retest failed jobs matching the wildcard",
+			ins:      &instruction{patterns: []string{"e2e-?"}},
+			selected: []string{"e2e-a", "e2e-c"},
+		},
+		{
+			caseName: "wildcard matches the whole name",
+			ins:      &instruction{patterns: []string{"ci*"}},
+			selected: []string{"ci / lint"},
+		},
+		{
+			caseName: "test a passed job",
+			ins:      &instruction{test: true, jobs: []string{"CI / unit test", "foo"}},
+			selected: []string{"ci / unit test"},
+			unknown:  []string{"foo"},
+		},
+		{
+			caseName: "running job cannot be tested",
+			ins:      &instruction{test: true, jobs: []string{"e2e-b"}},
+		},
+		{
+			caseName: "test all completed jobs",
+			ins:      &instruction{test: true, all: true},
+			selected: []string{"ci / lint", "ci / unit test", "e2e-a", "e2e-c"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			conclusions := tc.conclusions
+			if conclusions == nil {
+				conclusions = config.Default().Retest.Conclusions
+			}
+			selected, unknown := selectRuns(tc.ins, runs, conclusions)
+			assert.Equal(t, tc.selected, nameSet(selected))
+			assert.Equal(t, tc.unknown, unknown)
+		})
+	}
+}
+
+func TestRerunWorkflowRuns(t *testing.T) {
+	var rerunWorkflowRuns, rerunJobs []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/foo/bar/actions/jobs/{job}", func(w http.ResponseWriter, r *http.Request) {
+		// jobs 1 and 2 belong to the same workflow run
+		runID := map[string]int{"1": 100, "2": 100, "3": 200}[r.PathValue("job")]
+		_, _ = fmt.Fprintf(w, `{"id":%s,"run_id":%d}`, r.PathValue("job"), runID)
+	})
+	mux.HandleFunc("POST /repos/foo/bar/actions/runs/{run}/rerun-failed-jobs", func(w http.ResponseWriter, r *http.Request) {
+		rerunWorkflowRuns = append(rerunWorkflowRuns, r.PathValue("run"))
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("POST /repos/foo/bar/actions/jobs/{job}/rerun", func(w http.ResponseWriter, r *http.Request) {
+		rerunJobs = append(rerunJobs, r.PathValue("job"))
+		w.WriteHeader(http.StatusCreated)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ghClient := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	ghClient.BaseURL = baseURL
+
+	retestActor := &actor{
+		ghClient: ghClient,
+		// a noop logger for testing only
+		logger: slog.NewWithConfig(func(l *slog.Logger) {
+			l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+		}),
+	}
+	runs := []*github.CheckRun{{ID: github.Ptr[int64](1)}, {ID: github.Ptr[int64](2)}, {ID: github.Ptr[int64](3)}}
+
+	require.NoError(t, retestActor.rerunWorkflowRuns(context.Background(), "foo", "bar", runs))
+	assert.Equal(t, []string{"100", "200"}, rerunWorkflowRuns)
+
+	require.NoError(t, retestActor.rerunJobs(context.Background(), "foo", "bar", runs))
+	assert.Equal(t, []string{"1", "2", "3"}, rerunJobs)
+}
+
+func TestJobsTable(t *testing.T) {
+	runs := []*github.CheckRun{
+		{Name: github.Ptr("lint"), Status: github.Ptr("completed"), Conclusion: github.Ptr("failure")},
+		{Name: github.Ptr("build|test"), Status: github.Ptr("queued")},
+	}
+
+	assert.Equal(t, "| Job | Status | Conclusion |\n"+
+		"|-----|--------|------------|\n"+
+		"| build\\|test | queued | - |\n"+
+		"| lint | completed | failure |\n", jobsTable(runs))
+}
+
 func TestRetestCapture(t *testing.T) {
 	cases := []struct {
 		caseName string
@@ -111,7 +294,7 @@ func TestRetestCapture(t *testing.T) {
 			expect: false,
 		},
 		{
-			caseName: "retest actor does not capture unmatched retestRegexp comment body pull request",
+			caseName: "retest actor does not capture unmatched retest command comment body pull request",
 			event: actors.GenericEvent{
 				Event: github.IssueCommentEvent{
 					Comment: &github.IssueComment{
@@ -136,7 +319,107 @@ func TestRetestCapture(t *testing.T) {
 					l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
 				}),
 			}
-			assert.Equal(t, tc.expect, retestActor.Capture(tc.event))
+			event := tc.event
+			if commentEvent, ok := event.Event.(github.IssueCommentEvent); ok {
+				event.Commands = commands.Parse(commentEvent.GetComment().GetBody())
+			}
+			assert.Equal(t, tc.expect, retestActor.Capture(context.Background(), event))
+		})
+	}
+}
+
+func TestRetestHandler(t *testing.T) {
+	cases := []struct {
+		caseName     string
+		body         string
+		workflowRuns bool
+
+		expectRerunJobs         []int64
+		expectRerunWorkflowRuns []int64
+		expectComments          []string
+		expectReactions         map[int64][]string
+	}{
+		{
+			caseName:        "retest reruns the failed jobs",
+			body:            "/retest",
+			expectRerunJobs: []int64{1, 3},
+			expectReactions: map[int64][]string{10: {"rocket"}},
+		},
+		{
+			caseName:                "retest reruns the workflow runs of the failed jobs",
+			body:                    "/retest",
+			workflowRuns:            true,
+			expectRerunWorkflowRuns: []int64{100, 200},
+			expectReactions:         map[int64][]string{10: {"rocket"}},
+		},
+		{
+			caseName:        "test reruns the named job regardless of its conclusion",
+			body:            "/test lint",
+			expectRerunJobs: []int64{2},
+			expectReactions: map[int64][]string{10: {"rocket"}},
+		},
+		{
+			caseName:       "test replies with the jobs which cannot be found",
+			body:           "/test e2e",
+			expectComments: []string{"@bob These jobs '(e2e)' cannot be found, use '/test ?' to list the available jobs"},
+		},
+		{
+			caseName: "test lists the jobs",
+			body:     "/test ?",
+			expectComments: []string{"@bob The jobs of the pull request:\n\n" +
+				"| Job | Status | Conclusion |\n" +
+				"|-----|--------|------------|\n" +
+				"| build | completed | failure |\n" +
+				"| e2e-test | completed | timed_out |\n" +
+				"| lint | completed | success |\n"},
+		},
+		{
+			caseName:       "retest replies when no job has failed",
+			body:           "/retest lint",
+			expectComments: []string{"@bob The current checks run has all been run successfully and there is no need to rerun it again"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := githubtest.NewServer(t)
+			repo := server.Repo("foo/bar")
+			issue := repo.AddPullRequest(1, "alice", "abc")
+			repo.CheckRuns["abc"] = []*github.CheckRun{
+				{ID: github.Ptr[int64](1), Name: github.Ptr("build"), Status: github.Ptr("completed"), Conclusion: github.Ptr("failure")},
+				{ID: github.Ptr[int64](2), Name: github.Ptr("lint"), Status: github.Ptr("completed"), Conclusion: github.Ptr("success")},
+				{ID: github.Ptr[int64](3), Name: github.Ptr("e2e-test"), Status: github.Ptr("completed"), Conclusion: github.Ptr("timed_out")},
+			}
+			repo.Jobs = map[int64]int64{1: 100, 2: 100, 3: 200}
+
+			cfg := config.Default()
+			cfg.Retest.WorkflowRuns = tc.workflowRuns
+			retestActor := NewRetestActor(server.Client(), slog.NewWithConfig(func(l *slog.Logger) {
+				l.PushHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+			}), cfg)
+			event := actors.GenericEvent{
+				Event: github.IssueCommentEvent{
+					Repo:  &github.Repository{FullName: github.Ptr("foo/bar")},
+					Issue: &github.Issue{Number: github.Ptr(1), PullRequestLinks: &github.PullRequestLinks{}},
+					Comment: &github.IssueComment{
+						ID:   github.Ptr[int64](10),
+						User: &github.User{Login: github.Ptr("bob")},
+						Body: github.Ptr(tc.body),
+					},
+				},
+				Commands: commands.Parse(tc.body),
+			}
+			require.True(t, retestActor.Capture(context.Background(), event))
+			require.NoError(t, retestActor.Handler(context.Background()))
+
+			assert.Equal(t, tc.expectRerunJobs, repo.RerunJobs)
+			assert.Equal(t, tc.expectRerunWorkflowRuns, repo.RerunWorkflowRuns)
+			assert.Equal(t, tc.expectComments, issue.CommentBodies())
+			if tc.expectReactions == nil {
+				assert.Empty(t, repo.Reactions)
+			} else {
+				assert.Equal(t, tc.expectReactions, repo.Reactions)
+			}
 		})
 	}
 }
@@ -1,9 +1,24 @@
 package actors
 
+import (
+	"context"
+
+	"github.com/ShyunnY/actbot/internal/commands"
+)
+
 // Constant definitions related to GitHub labels
 const (
 	// HelpWantedLabel The value of the help wanted label has been defined
 	HelpWantedLabel = "help wanted"
+
+	// LGTMLabel The value of the lgtm label has been defined
+	LGTMLabel = "lgtm"
+
+	// ApprovedLabel The value of the approved label has been defined
+	ApprovedLabel = "approved"
+
+	// HoldLabel The value of the hold label has been defined
+	HoldLabel = "do-not-merge/hold"
 )
 
 // Constant definitions related to GitHub comment reaction
@@ -15,15 +30,32 @@ const (
 	RocketReaction = "rocket"
 )
 
+// Actor the context passed to Capture and Handler is cancelled once the deadline of the run expires,
+// or when the run is interrupted, every GitHub call of the actor must be made with it.
 type Actor interface {
-	Handler() error
+	Handler(ctx context.Context) error
 
-	Capture(event GenericEvent) bool
+	Capture(ctx context.Context, event GenericEvent) bool
 
 	Name() string
+
+	// Commands the names of the commands handled by the actor without the leading slash,
+	// it is empty for actors which only react to events
+	Commands() []string
+}
+
+// Exempter is implemented by actors whose command can be used by some users without fulfilling the permission
+// requirement of the actor, e.g. the author closing their own issue. Exempt is called once the actor captured
+// the event, the other users are checked against the requirement and denied by the dispatcher.
+type Exempter interface {
+	Exempt() bool
 }
 
 type GenericEvent struct {
 	// This represents the actual GitHub events
 	Event any
+
+	// Commands the commands of the comment carried by the event, parsed once by the dispatcher.
+	// It is empty for events which are not triggered by a comment.
+	Commands []commands.Command
 }
@@ -2,15 +2,40 @@ package actors
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"strings"
 
 	"github.com/google/go-github/v72/github"
 )
 
-func AddComment(ghClient *github.Client, content, fullName string, issueNumber int) error {
+// PerPage the maximum number of items per page accepted by the GitHub API
+const PerPage = 100
+
+// ListAll collects the items of every page, list is called with the options of each page starting from the first one.
+func ListAll[T any](list func(opts github.ListOptions) ([]T, *github.Response, error)) ([]T, error) {
+	var (
+		all  []T
+		opts = github.ListOptions{PerPage: PerPage}
+	)
+	for {
+		items, resp, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+
+		if resp == nil || resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func AddComment(ctx context.Context, ghClient *github.Client, content, fullName string, issueNumber int) error {
 	owner, repo := GetOwnerRepo(fullName)
 	if _, _, err := ghClient.Issues.CreateComment(
-		context.Background(),
+		ctx,
 		owner,
 		repo,
 		issueNumber,
@@ -24,10 +49,10 @@ func AddComment(ghClient *github.Client, content, fullName string, issueNumber i
 	return nil
 }
 
-func AddLabelToIssue(ghClient *github.Client, fullName string, issueNumber int, label ...string) error {
+func AddLabelToIssue(ctx context.Context, ghClient *github.Client, fullName string, issueNumber int, label ...string) error {
 	owner, repo := GetOwnerRepo(fullName)
 	if _, _, err := ghClient.Issues.AddLabelsToIssue(
-		context.Background(),
+		ctx,
 		owner,
 		repo,
 		issueNumber,
@@ -39,11 +64,11 @@ func AddLabelToIssue(ghClient *github.Client, fullName string, issueNumber int,
 	return nil
 }
 
-func RemoveLabelToIssue(ghClient *github.Client, fullName string, issueNumber int, label string) error {
+func RemoveLabelToIssue(ctx context.Context, ghClient *github.Client, fullName string, issueNumber int, label string) error {
 	owner, repo := GetOwnerRepo(fullName)
 
 	issue, _, err := ghClient.Issues.Get(
-		context.Background(),
+		ctx,
 		owner,
 		repo,
 		issueNumber,
@@ -51,22 +76,12 @@ func RemoveLabelToIssue(ghClient *github.Client, fullName string, issueNumber in
 	switch {
 	case err != nil:
 		return err
-	case issue == nil || len(issue.Labels) == 0:
+	case issue == nil || !HasLabel(issue.Labels, label):
 		return nil
-	default:
-		ret := true
-		for _, issueLabel := range issue.Labels {
-			if issueLabel.GetName() == label {
-				ret = false
-			}
-		}
-		if ret {
-			return nil
-		}
 	}
 
 	if _, err := ghClient.Issues.RemoveLabelForIssue(
-		context.Background(),
+		ctx,
 		owner,
 		repo,
 		issueNumber,
@@ -78,10 +93,21 @@ func RemoveLabelToIssue(ghClient *github.Client, fullName string, issueNumber in
 	return nil
 }
 
-func AddReaction(ghClient *github.Client, reaction, fullName string, issueCommentID int64) error {
+// HasLabel reports whether the labels contain the label with the name
+func HasLabel(labels []*github.Label, name string) bool {
+	for _, label := range labels {
+		if label.GetName() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func AddReaction(ctx context.Context, ghClient *github.Client, reaction, fullName string, issueCommentID int64) error {
 	owner, repo := GetOwnerRepo(fullName)
 	if _, _, err := ghClient.Reactions.CreateIssueCommentReaction(
-		context.Background(),
+		ctx,
 		owner,
 		repo,
 		issueCommentID,
@@ -93,10 +119,25 @@ func AddReaction(ghClient *github.Client, reaction, fullName string, issueCommen
 	return nil
 }
 
-func GetPRFromIssue(ghClient *github.Client, fullName string, issue *github.Issue) (*github.PullRequest, error) {
+func AddPullRequestCommentReaction(ctx context.Context, ghClient *github.Client, reaction, fullName string, pullRequestCommentID int64) error {
+	owner, repo := GetOwnerRepo(fullName)
+	if _, _, err := ghClient.Reactions.CreatePullRequestCommentReaction(
+		ctx,
+		owner,
+		repo,
+		pullRequestCommentID,
+		reaction,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func GetPRFromIssue(ctx context.Context, ghClient *github.Client, fullName string, issue *github.Issue) (*github.PullRequest, error) {
 	owner, repo := GetOwnerRepo(fullName)
 	pullRequest, _, err := ghClient.PullRequests.Get(
-		context.Background(),
+		ctx,
 		owner,
 		repo,
 		issue.GetNumber(),
@@ -108,6 +149,54 @@ func GetPRFromIssue(ghClient *github.Client, fullName string, issue *github.Issu
 	return pullRequest, nil
 }
 
+// GetAuthenticatedLogin returns the login of the account the client is authenticated as.
+// The login is empty for installation tokens, e.g. the GITHUB_TOKEN of workflows, which are not allowed to read it,
+// and for anonymous clients, e.g. in dry-run mode without a token.
+func GetAuthenticatedLogin(ctx context.Context, ghClient *github.Client) (string, error) {
+	user, _, err := ghClient.Users.Get(ctx, "")
+	if err != nil {
+		var errResp *github.ErrorResponse
+		if errors.As(err, &errResp) && errResp.Response != nil &&
+			(errResp.Response.StatusCode == http.StatusForbidden || errResp.Response.StatusCode == http.StatusUnauthorized) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return user.GetLogin(), nil
+}
+
+// GetFileContent reads the file at the given path and ref through the contents API.
+// The returned bool reports whether the file exists, a missing file is not treated as an error.
+func GetFileContent(ctx context.Context, ghClient *github.Client, fullName, path, ref string) ([]byte, bool, error) {
+	owner, repo := GetOwnerRepo(fullName)
+	fileContent, _, _, err := ghClient.Repositories.GetContents(
+		ctx,
+		owner,
+		repo,
+		path,
+		&github.RepositoryContentGetOptions{Ref: ref},
+	)
+	if err != nil {
+		var errResp *github.ErrorResponse
+		if errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if fileContent == nil {
+		// the path points to a directory
+		return nil, false, nil
+	}
+
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return []byte(content), true, nil
+}
+
 func GetOwnerRepo(fullName string) (owner, repo string) {
 	split := strings.Split(fullName, "/")
 	owner = split[0]
@@ -0,0 +1,169 @@
+package actors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPagedLabelServer serves the labels of foo/bar in pages linked by the Link header like GitHub does
+func newPagedLabelServer(t *testing.T, total int, pages *[]string) *github.Client {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/foo/bar/labels" {
+			http.NotFound(w, r)
+			return
+		}
+
+		page, perPage := 1, 30
+		if p := r.URL.Query().Get("page"); len(p) != 0 {
+			page, _ = strconv.Atoi(p)
+		}
+		if p := r.URL.Query().Get("per_page"); len(p) != 0 {
+			perPage, _ = strconv.Atoi(p)
+		}
+		*pages = append(*pages, strconv.Itoa(page))
+
+		start, end := (page-1)*perPage, min(page*perPage, total)
+		if end < total {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/foo/bar/labels?page=%d&per_page=%d>; rel="next"`, server.URL, page+1, perPage))
+		}
+		_, _ = fmt.Fprint(w, "[")
+		for i := start; i < end; i++ {
+			if i != start {
+				_, _ = fmt.Fprint(w, ",")
+			}
+			_, _ = fmt.Fprintf(w, `{"name":"label-%d"}`, i)
+		}
+		_, _ = fmt.Fprint(w, "]")
+	}))
+	t.Cleanup(server.Close)
+
+	ghClient := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	ghClient.BaseURL = baseURL
+
+	return ghClient
+}
+
+func TestListAll(t *testing.T) {
+	cases := []struct {
+		caseName string
+		total    int
+		pages    []string
+	}{
+		{
+			caseName: "single page",
+			total:    10,
+			pages:    []string{"1"},
+		},
+		{
+			caseName: "several pages",
+			total:    250,
+			pages:    []string{"1", "2", "3"},
+		},
+		{
+			caseName: "empty list",
+			total:    0,
+			pages:    []string{"1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			var pages []string
+			ghClient := newPagedLabelServer(t, tc.total, &pages)
+
+			labels, err := ListAll(func(opts github.ListOptions) ([]*github.Label, *github.Response, error) {
+				assert.Equal(t, PerPage, opts.PerPage)
+				return ghClient.Issues.ListLabels(context.Background(), "foo", "bar", &opts)
+			})
+			require.NoError(t, err)
+			require.Len(t, labels, tc.total)
+			for i, label := range labels {
+				assert.Equal(t, fmt.Sprintf("label-%d", i), label.GetName())
+			}
+			assert.Equal(t, tc.pages, pages)
+		})
+	}
+}
+
+func TestListAllError(t *testing.T) {
+	var pages []string
+	ghClient := newPagedLabelServer(t, 10, &pages)
+
+	_, err := ListAll(func(opts github.ListOptions) ([]*github.Label, *github.Response, error) {
+		return ghClient.Issues.ListLabels(context.Background(), "foo", "baz", &opts)
+	})
+	assert.Error(t, err)
+}
+
+func TestHasLabel(t *testing.T) {
+	labels := []*github.Label{{Name: github.Ptr("lgtm")}, {Name: github.Ptr("kind/bug")}}
+
+	assert.True(t, HasLabel(labels, "kind/bug"))
+	assert.False(t, HasLabel(labels, "approved"))
+	assert.False(t, HasLabel(nil, "lgtm"))
+}
+
+func TestGetAuthenticatedLogin(t *testing.T) {
+	cases := []struct {
+		caseName    string
+		status      int
+		expectLogin string
+		expectErr   bool
+	}{
+		{
+			caseName:    "user token",
+			status:      http.StatusOK,
+			expectLogin: "actbot",
+		},
+		{
+			caseName: "installation token is not allowed to read the user",
+			status:   http.StatusForbidden,
+		},
+		{
+			caseName: "anonymous client is not authenticated",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			caseName:  "other failures are returned",
+			status:    http.StatusNotFound,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = fmt.Fprint(w, `{"login":"actbot"}`)
+			}))
+			defer server.Close()
+
+			ghClient := github.NewClient(nil)
+			baseURL, err := url.Parse(server.URL + "/")
+			require.NoError(t, err)
+			ghClient.BaseURL = baseURL
+
+			login, err := GetAuthenticatedLogin(context.Background(), ghClient)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectLogin, login)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ShyunnY/actbot/internal/config"
+)
+
+const (
+	runCommand   = "run"
+	serveCommand = "serve"
+)
+
+const usage = `Usage:
+  actbot                 run inside GitHub Actions, the inputs are read from the environment
+  actbot run [flags]     replay a saved event payload locally
+  actbot serve [flags]   run as a webhook server
+
+`
+
+// Execute runs the subcommand given by the command line arguments, the program name excluded.
+// The subcommand stops once ctx is cancelled.
+func Execute(ctx context.Context, args []string) error {
+	return execute(ctx, args, os.Stderr)
+}
+
+func execute(ctx context.Context, args []string, output io.Writer) error {
+	if len(args) == 0 {
+		printUsage(output)
+		return errors.New("missing subcommand")
+	}
+
+	switch args[0] {
+	case runCommand:
+		runFlags, opts := newRunFlagSet(output)
+		if err := parseFlags(runFlags, args[1:]); err != nil {
+			return ignoreHelp(err)
+		}
+		if err := completeOptions(opts); err != nil {
+			return err
+		}
+		return Run(ctx, *opts)
+	case serveCommand:
+		serveFlags, opts := newServeFlagSet(output)
+		if err := parseFlags(serveFlags, args[1:]); err != nil {
+			return ignoreHelp(err)
+		}
+		return Serve(ctx, *opts)
+	default:
+		printUsage(output)
+		return fmt.Errorf("unknown subcommand '%s'", args[0])
+	}
+}
+
+func printUsage(output io.Writer) {
+	_, _ = fmt.Fprint(output, usage)
+	runFlags, _ := newRunFlagSet(output)
+	serveFlags, _ := newServeFlagSet(output)
+	for _, fs := range []*flag.FlagSet{runFlags, serveFlags} {
+		_, _ = fmt.Fprintf(output, "Flags of %s:\n", fs.Name())
+		fs.PrintDefaults()
+	}
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	return nil
+}
+
+func ignoreHelp(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+
+	return err
+}
+
+func newRunFlagSet(output io.Writer) (*flag.FlagSet, *Options) {
+	opts := &Options{}
+	runFlags := flag.NewFlagSet(runCommand, flag.ContinueOnError)
+	runFlags.SetOutput(output)
+
+	runFlags.StringVar(&opts.Event, "event", "", "name of the GitHub event, e.g. issue_comment (required)")
+	runFlags.StringVar(&opts.EventPath, "payload", "", "path of the file containing the event payload (required)")
+	runFlags.StringVar(&opts.Token, "token", os.Getenv("GITHUB_TOKEN"), "GitHub token, optional in dry-run mode")
+	addAppFlags(runFlags, &opts.Credentials)
+	addEndpointFlags(runFlags, &opts.Endpoint)
+	runFlags.StringVar(&opts.Repo, "repo", "", "full name of the repository, defaults to the repository of the payload")
+	runFlags.StringVar(&opts.Workspace, "workspace", ".", "directory of the checked-out repository")
+	runFlags.StringVar(&opts.ConfigPath, "config", config.DefaultPath, "path of the configuration file relative to the workspace")
+	runFlags.BoolVar(&opts.DryRun, "dry-run", false, "log every mutation instead of sending it to GitHub")
+	runFlags.DurationVar(&opts.Timeout, "timeout", defaultTimeout, "deadline of the whole run, 0 disables it")
+	runFlags.DurationVar(&opts.CallTimeout, "call-timeout", defaultCallTimeout, "deadline of each request sent to GitHub, 0 disables it")
+
+	return runFlags, opts
+}
+
+func newServeFlagSet(output io.Writer) (*flag.FlagSet, *ServerOptions) {
+	opts := &ServerOptions{}
+	serveFlags := flag.NewFlagSet(serveCommand, flag.ContinueOnError)
+	serveFlags.SetOutput(output)
+
+	serveFlags.StringVar(&opts.Addr, "addr", ":8080", "address to listen on")
+	serveFlags.StringVar(&opts.Path, "path", "/webhook", "path receiving webhook deliveries")
+	serveFlags.StringVar(&opts.Secret, "secret", os.Getenv("WEBHOOK_SECRET"), "webhook secret used to verify deliveries (required)")
+	serveFlags.StringVar(&opts.Token, "token", os.Getenv("GITHUB_TOKEN"), "GitHub token")
+	addAppFlags(serveFlags, &opts.Credentials)
+	addEndpointFlags(serveFlags, &opts.Endpoint)
+	serveFlags.StringVar(&opts.ConfigPath, "config", config.DefaultPath, "path of the configuration file in each repository")
+	serveFlags.IntVar(&opts.Workers, "workers", 4, "number of deliveries processed concurrently")
+	serveFlags.IntVar(&opts.QueueSize, "queue-size", 100, "number of deliveries waiting to be processed")
+	serveFlags.IntVar(&opts.DeliveryCacheSize, "delivery-cache-size", 1024, "number of recent delivery ids remembered to drop redeliveries")
+	serveFlags.BoolVar(&opts.DryRun, "dry-run", false, "log every mutation instead of sending it to GitHub")
+	serveFlags.DurationVar(&opts.Timeout, "timeout", defaultTimeout, "deadline of processing each delivery, 0 disables it")
+	serveFlags.DurationVar(&opts.CallTimeout, "call-timeout", defaultCallTimeout, "deadline of each request sent to GitHub, 0 disables it")
+
+	return serveFlags, opts
+}
+
+// addAppFlags adds the flags of the GitHub App credentials
+func addAppFlags(fs *flag.FlagSet, creds *Credentials) {
+	fs.Int64Var(&creds.AppID, "app-id", 0, "id of the GitHub App, takes precedence over the token")
+	fs.Int64Var(&creds.AppInstallationID, "app-installation-id", 0, "installation of the GitHub App, discovered when omitted")
+	fs.Func("app-private-key-file", "path of the PEM encoded private key of the GitHub App", func(path string) error {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		creds.AppPrivateKey = key
+		return nil
+	})
+}
+
+// addEndpointFlags adds the flags of GitHub Enterprise Server, they default to the GitHub Actions environment
+func addEndpointFlags(fs *flag.FlagSet, endpoint *Endpoint) {
+	detected := endpointFromEnv()
+	fs.StringVar(&endpoint.APIURL, "api-url", detected.APIURL, "REST API URL of GitHub Enterprise Server, github.com is used when empty")
+	fs.StringVar(&endpoint.UploadURL, "upload-url", detected.UploadURL, "upload URL of GitHub Enterprise Server, derived from the API URL when empty")
+}
+
+// completeOptions validates the required flags and fills the repository from the payload when it is not provided
+func completeOptions(opts *Options) error {
+	switch {
+	case len(opts.Event) == 0:
+		return errors.New("flag --event is required")
+	case len(opts.EventPath) == 0:
+		return errors.New("flag --payload is required")
+	case len(opts.Repo) != 0:
+		if !validRepo(opts.Repo) {
+			return fmt.Errorf("invalid flag --repo '%s', expected 'owner/name' format", opts.Repo)
+		}
+		return nil
+	}
+
+	payload, err := readGitHubEvent(opts.EventPath)
+	if err != nil {
+		return err
+	}
+	if opts.Repo, err = payloadRepo(payload); err != nil {
+		return fmt.Errorf("unmarshal payload '%s': %w", opts.EventPath, err)
+	}
+	if len(opts.Repo) != 0 && !validRepo(opts.Repo) {
+		return fmt.Errorf("invalid repository '%s' of payload '%s', expected 'owner/name' format", opts.Repo, opts.EventPath)
+	}
+
+	return nil
+}
+
+// validRepo reports whether the full name of the repository is in "owner/name" format
+func validRepo(fullName string) bool {
+	owner, name, ok := strings.Cut(fullName, "/")
+	return ok && len(owner) != 0 && len(name) != 0 && !strings.Contains(name, "/")
+}
+
+// payloadRepo returns the full name of the repository the event belongs to
+func payloadRepo(payload []byte) (string, error) {
+	var event struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return "", err
+	}
+
+	return event.Repository.FullName, nil
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecute(t *testing.T) {
+	payloadPath := filepath.Join(t.TempDir(), "event.json")
+	require.NoError(t, os.WriteFile(payloadPath, []byte(`{"repository":{"full_name":"foo/bar"}}`), 0o600))
+
+	cases := []struct {
+		caseName string
+		args     []string
+		errMsg   string
+	}{
+		{
+			caseName: "missing subcommand",
+			args:     nil,
+			errMsg:   "missing subcommand",
+		},
+		{
+			caseName: "unknown subcommand",
+			args:     []string{"help"},
+			errMsg:   "unknown subcommand 'help'",
+		},
+		{
+			caseName: "webhook secret is required",
+			args:     []string{"serve", "--secret", ""},
+			errMsg:   "empty webhook secret",
+		},
+		{
+			caseName: "unexpected arguments",
+			args:     []string{"run", "--event", "issue_comment", "extra"},
+			errMsg:   "unexpected arguments [extra]",
+		},
+		{
+			caseName: "help is not an error",
+			args:     []string{"run", "-h"},
+		},
+		{
+			caseName: "event is required",
+			args:     []string{"run", "--payload", payloadPath},
+			errMsg:   "flag --event is required",
+		},
+		{
+			caseName: "payload is required",
+			args:     []string{"run", "--event", "issue_comment"},
+			errMsg:   "flag --payload is required",
+		},
+		{
+			caseName: "unknown flag",
+			args:     []string{"run", "--foo"},
+			errMsg:   "flag provided but not defined: -foo",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			err := execute(context.Background(), tc.args, io.Discard)
+			if len(tc.errMsg) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.errMsg)
+		})
+	}
+}
+
+func TestCompleteOptions(t *testing.T) {
+	payloadPath := filepath.Join(t.TempDir(), "event.json")
+	require.NoError(t, os.WriteFile(payloadPath, []byte(`{"repository":{"full_name":"foo/bar"}}`), 0o600))
+
+	opts := &Options{Event: "issue_comment", EventPath: payloadPath}
+	require.NoError(t, completeOptions(opts))
+	assert.Equal(t, "foo/bar", opts.Repo)
+
+	opts = &Options{Event: "issue_comment", EventPath: payloadPath, Repo: "baz/qux"}
+	require.NoError(t, completeOptions(opts))
+	assert.Equal(t, "baz/qux", opts.Repo)
+
+	for _, repo := range []string{"foo", "foo/", "/bar", "foo/bar/baz"} {
+		opts = &Options{Event: "issue_comment", EventPath: payloadPath, Repo: repo}
+		assert.ErrorContains(t, completeOptions(opts), "expected 'owner/name' format", repo)
+	}
+}
@@ -2,19 +2,28 @@ package internal
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/google/go-github/v72/github"
 	"github.com/gookit/slog"
 	"github.com/gookit/slog/handler"
+	"github.com/hashicorp/go-multierror"
 	"github.com/jinzhu/copier"
 	"golang.org/x/oauth2"
-	oauthGh "golang.org/x/oauth2/github"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubapp"
+	"github.com/ShyunnY/actbot/internal/permission"
+	"github.com/ShyunnY/actbot/internal/ratelimit"
 )
 
 // initialize the global logger
@@ -26,26 +35,109 @@ var logger = func() *slog.Logger {
 	})
 }()
 
-func Setup() error {
-	var (
-		ghToken     = os.Getenv("token")
-		ghEvent     = os.Getenv("GITHUB_EVENT_NAME")
-		ghEventPath = os.Getenv("GITHUB_EVENT_PATH")
-	)
+// Credentials how actbot authenticates to GitHub, a GitHub App takes precedence over the token
+type Credentials struct {
+	// Token the GitHub token, it is optional in dry-run mode
+	Token string
+
+	// AppID the id of the GitHub App
+	AppID int64
+
+	// AppPrivateKey the PEM encoded private key of the GitHub App
+	AppPrivateKey []byte
+
+	// AppInstallationID the installation of the GitHub App, it is discovered when it is zero
+	AppInstallationID int64
+}
+
+// Options the inputs of a single run
+type Options struct {
+	Credentials
+	Endpoint
+
+	// Event the name of the GitHub event, e.g. issue_comment
+	Event string
+
+	// EventPath path of the file containing the event payload
+	EventPath string
+
+	// Repo the full name of the repository, e.g. ShyunnY/actbot
+	Repo string
+
+	// Workspace the directory of the checked-out repository
+	Workspace string
+
+	// ConfigPath path of the configuration file relative to the repository root
+	ConfigPath string
+
+	// DryRun logs every request which would mutate the repository instead of sending it
+	DryRun bool
+
+	// Timeout the deadline of the whole run, the run is not bounded when it is zero
+	Timeout time.Duration
+
+	// CallTimeout the deadline of each request sent to GitHub, requests are not bounded when it is zero
+	CallTimeout time.Duration
+}
+
+// Setup runs actbot inside GitHub Actions, the inputs are provided by the environment variables.
+// The returned error combines the failures of all actors, the run is aborted once ctx is cancelled.
+func Setup(ctx context.Context) error {
+	creds, err := credentialsFromEnv()
+	if err != nil {
+		return err
+	}
+	timeout, err := parseTimeout(os.Getenv("timeout"), defaultTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+	callTimeout, err := parseTimeout(os.Getenv("call_timeout"), defaultCallTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid call timeout: %w", err)
+	}
 
-	gitHubClient, err := InitGitHubClient(ghToken)
+	opts := Options{
+		Credentials: creds,
+		Endpoint:    endpointFromEnv(),
+		Event:       os.Getenv("GITHUB_EVENT_NAME"),
+		EventPath:   os.Getenv("GITHUB_EVENT_PATH"),
+		Repo:        os.Getenv("GITHUB_REPOSITORY"),
+		Workspace:   os.Getenv("GITHUB_WORKSPACE"),
+		ConfigPath:  os.Getenv("config"),
+		Timeout:     timeout,
+		CallTimeout: callTimeout,
+	}
+
+	return Run(ctx, opts)
+}
+
+// Run dispatches the event described by the options to the actors,
+// the run is aborted once ctx is cancelled or the timeout of the options expires.
+func Run(ctx context.Context, opts Options) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	gitHubClient, err := newGitHubClient(opts.Credentials, opts.Endpoint, opts.Repo, opts.DryRun, opts.CallTimeout)
 	if err != nil {
-		exit("failed to init GitHub client by err: %v", err)
+		return fmt.Errorf("failed to init GitHub client by err: %w", err)
 	}
 
-	if err := dispatch(ghEvent, ghEventPath, gitHubClient); err != nil {
-		exit("failed to dispatch event by err: %v", err)
+	cfg, err := loadConfig(ctx, gitHubClient, opts.Repo, opts.Workspace, opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration by err: %w", err)
+	}
+
+	if err := dispatch(ctx, opts.Event, opts.EventPath, gitHubClient, cfg); err != nil {
+		return fmt.Errorf("failed to dispatch event by err: %w", err)
 	}
 
 	return nil
 }
 
-func dispatch(ghEvent, ghEventPath string, ghClient *github.Client) error {
+func dispatch(ctx context.Context, ghEvent, ghEventPath string, ghClient *github.Client, cfg *config.Config) error {
 	if len(ghEvent) == 0 {
 		return errors.New("empty github event")
 	}
@@ -54,38 +146,350 @@ func dispatch(ghEvent, ghEventPath string, ghClient *github.Client) error {
 		return err
 	}
 
-	switch ghEvent {
-	case string(IssueComment):
-		var (
-			evt          github.IssueCommentEvent
-			genericEvent actors.GenericEvent
-		)
-		if err := json.Unmarshal(ghEventBytes, &evt); err != nil {
-			return fmt.Errorf("unmarshal '%s' github event: %w", IssueComment, err)
+	return dispatchPayload(ctx, ghEvent, ghEventBytes, ghClient, cfg)
+}
+
+// dispatchPayload decodes the payload of the event and runs the actors registered for it
+func dispatchPayload(ctx context.Context, ghEvent string, payload []byte, ghClient *github.Client, cfg *config.Config) error {
+	eventType := GitHubEventType(ghEvent)
+	decode, ok := eventDecoders[eventType]
+	if !ok {
+		return fmt.Errorf("unsupported github event '%s'", ghEvent)
+	}
+
+	evt, err := decode(payload)
+	if err != nil {
+		return fmt.Errorf("unmarshal '%s' github event: %w", eventType, err)
+	}
+
+	var checker *permission.Checker
+	if _, fullName, _, ok := commandSource(evt); ok {
+		checker = permission.NewChecker(ghClient, fullName)
+	}
+
+	body, action, ok := commentBody(evt)
+	if !ok {
+		results, err := runActors(ctx, eventType, actors.GenericEvent{Event: evt}, ghClient, checker, cfg, true)
+		return multierror.Append(err, reportFailures(ctx, ghClient, cfg, evt, results)).ErrorOrNil()
+	}
+	// editing or deleting a comment must not run its commands once more
+	if action != createdAction {
+		logger.Infof("ignore the '%s' action of %s event, only created comments carry commands", action, eventType)
+		return nil
+	}
+
+	// the comment is parsed only once, each command is then offered to the actors on its own in textual order.
+	// The issue of the payload is refreshed once a command may have changed it, so that a later command always sees
+	// the effects of the earlier ones. A failed command does not prevent the following ones from running,
+	// the failures are combined into the returned error.
+	var (
+		cmds       = commands.Parse(body)
+		report     = &summary{}
+		errG       *multierror.Error
+		results    []actorResult
+		cmdResults []actorResult
+	)
+	for _, cmd := range cmds {
+		// the remaining commands cannot be run anymore once the run has been aborted
+		if err := ctx.Err(); err != nil {
+			errG = multierror.Append(errG, fmt.Errorf("command '%s' aborted: %w", cmd.Raw, err))
+			break
 		}
-		genericEvent.Event = evt
 
-		for _, fn := range actorMap[IssueComment] {
-			event, err := copyEvent(&genericEvent)
+		if changesState(cmdResults) {
+			refreshed, err := refreshIssue(ctx, ghClient, evt)
 			if err != nil {
-				return err
+				// the command still runs against the payload, which may be stale
+				errG = multierror.Append(errG, fmt.Errorf("refresh issue before command '%s': %w", cmd.Raw, err))
+			} else {
+				evt = refreshed
 			}
+		}
 
-			actor := fn(ghClient, logger)
-			if actor.Capture(*event) {
-				if err = actor.Handler(); err != nil {
-					exit("actor %s handle by err: %s", actor.Name(), err)
-				}
+		event := actors.GenericEvent{Event: evt, Commands: []commands.Command{cmd}}
+		cmdResults, err = runActors(ctx, eventType, event, ghClient, checker, cfg, len(cmds) == 1)
+		if err != nil {
+			errG = multierror.Append(errG, err)
+		}
+		report.add(cmd, cmdResults)
+		results = append(results, cmdResults...)
+	}
 
-				logger.Infof("actor %s successfully handle %s event", actor.Name(), IssueComment)
-			}
+	// a single command speaks for itself through the replies of its actor, and the failures are reported on their own
+	if len(cmds) > 1 {
+		if err := postSummary(ctx, ghClient, cfg, evt, report); err != nil {
+			errG = multierror.Append(errG, err)
 		}
+	} else if err := reportFailures(ctx, ghClient, cfg, evt, results); err != nil {
+		errG = multierror.Append(errG, err)
+	}
 
+	return errG.ErrorOrNil()
+}
+
+// runActors offers the event to every enabled actor of the event type and runs the actors which captured it,
+// the returned results only cover the actors which captured the event. A failed actor does not prevent
+// the following actors from running, the failures are combined into the returned error.
+// replyDenial controls whether a user who is not allowed to use a command gets a denial comment.
+func runActors(
+	ctx context.Context,
+	eventType GitHubEventType,
+	genericEvent actors.GenericEvent,
+	ghClient *github.Client,
+	checker *permission.Checker,
+	cfg *config.Config,
+	replyDenial bool,
+) ([]actorResult, error) {
+	var (
+		results []actorResult
+		errG    *multierror.Error
+	)
+	for _, fn := range actorMap[eventType] {
+		if err := ctx.Err(); err != nil {
+			return results, multierror.Append(errG, fmt.Errorf("aborted before all actors ran: %w", err))
+		}
+
+		// every actor gets its own copy of the event, so that an actor cannot affect the others
+		event, err := copyEvent(&genericEvent)
+		if err != nil {
+			return results, multierror.Append(errG, err)
+		}
+
+		actor := fn(ghClient, logger, cfg)
+		if !cfg.ActorEnabled(actor.Name()) {
+			logger.Infof("actor %s is disabled by configuration", actor.Name())
+			continue
+		}
+
+		if !actor.Capture(ctx, *event) {
+			continue
+		}
+
+		// the users exempted by the actor do not need to fulfill the requirement
+		command := commandName(event.Commands)
+		allowed := exempted(actor)
+		if !allowed {
+			allowed, err = authorize(ctx, ghClient, checker, cfg, actor.Name(), command, event.Event, replyDenial)
+		}
+		if err != nil {
+			logger.Errorf("actor %s authorize by err: %v", actor.Name(), err)
+			results = append(results, actorResult{actor: actor.Name(), outcome: failed, err: err})
+			errG = multierror.Append(errG, fmt.Errorf("actor %s authorize by err: %w", actor.Name(), err))
+			continue
+		}
+		if !allowed {
+			results = append(results, actorResult{
+				actor:       actor.Name(),
+				outcome:     denied,
+				requirement: cfg.Permission(actor.Name(), command).String(),
+			})
+			continue
+		}
+
+		if err = actor.Handler(ctx); err != nil {
+			logger.Errorf("actor %s handle by err: %v", actor.Name(), err)
+			results = append(results, actorResult{actor: actor.Name(), outcome: failed, err: err})
+			errG = multierror.Append(errG, fmt.Errorf("actor %s handle by err: %w", actor.Name(), err))
+			continue
+		}
+
+		logger.Infof("actor %s successfully handle %s event", actor.Name(), eventType)
+		results = append(results, actorResult{actor: actor.Name(), outcome: handled})
+	}
+
+	return results, errG.ErrorOrNil()
+}
+
+// reportFailures replies with the actors which failed to handle the event when it is enabled by the configuration,
+// nothing is reported for events which are not triggered by a command.
+func reportFailures(ctx context.Context, ghClient *github.Client, cfg *config.Config, event any, results []actorResult) error {
+	login, fullName, number, ok := commandSource(event)
+	if !ok || !cfg.ReportFailures {
+		return nil
+	}
+
+	body, ok, err := renderFailures(cfg, login, results)
+	if err != nil || !ok {
+		return err
+	}
+
+	return actors.AddComment(ctx, ghClient, body, fullName, number)
+}
+
+// postSummary replies to the comment with the result of each of its commands
+func postSummary(ctx context.Context, ghClient *github.Client, cfg *config.Config, event any, report *summary) error {
+	login, fullName, number, _ := commandSource(event)
+	body, err := report.render(cfg, login)
+	if err != nil {
+		return err
+	}
+
+	return actors.AddComment(ctx, ghClient, body, fullName, number)
+}
+
+// authorize checks whether the user who wrote the command fulfills the permission requirement of the command,
+// and replies with a denial comment instead of silently ignoring the command when the user is not allowed
+// unless replyDenial is false. Events which are not triggered by a command are always allowed.
+// command is the name of the command as written by the user, it is empty for events which are not comments, e.g. reviews.
+func authorize(
+	ctx context.Context,
+	ghClient *github.Client,
+	checker *permission.Checker,
+	cfg *config.Config,
+	actorName string,
+	command string,
+	event any,
+	replyDenial bool,
+) (bool, error) {
+	login, fullName, number, ok := commandSource(event)
+	if !ok || checker == nil {
+		return true, nil
+	}
+
+	requirement := cfg.Permission(actorName, command)
+	allowed, err := checker.Allowed(ctx, login, requirement)
+	if err != nil || allowed {
+		return allowed, err
+	}
+	logger.Warnf("user '%s' is not allowed to use the command of actor %s", login, actorName)
+	if !replyDenial {
+		return false, nil
+	}
+
+	reply, err := config.RenderReply(cfg.Replies.PermissionDenied, config.ReplyData{
+		User:        login,
+		Command:     command,
+		Actor:       actorName,
+		Requirement: requirement.String(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return false, actors.AddComment(ctx, ghClient, reply, fullName, number)
+}
+
+// exempted reports whether the actor lets the user who wrote the command use it regardless of the requirement
+func exempted(actor actors.Actor) bool {
+	exempter, ok := actor.(actors.Exempter)
+	return ok && exempter.Exempt()
+}
+
+// commandName returns the name of the command offered to the actors, each command of a comment is offered on its own
+func commandName(cmds []commands.Command) string {
+	if len(cmds) != 1 {
+		return ""
+	}
+
+	return cmds[0].Name
+}
+
+// commentBody returns the body of the comment carrying commands and the action of the event, e.g. created or edited,
+// ok is false for events which are not comments
+func commentBody(event any) (body, action string, ok bool) {
+	switch evt := event.(type) {
+	case github.IssueCommentEvent:
+		return evt.GetComment().GetBody(), evt.GetAction(), true
+	case github.PullRequestReviewCommentEvent:
+		return evt.GetComment().GetBody(), evt.GetAction(), true
 	default:
-		return errors.New("unsupported github event")
+		return "", "", false
 	}
+}
 
-	return nil
+// changesState reports whether any actor handled the command or failed halfway, denied commands change nothing
+func changesState(results []actorResult) bool {
+	return slices.ContainsFunc(results, func(r actorResult) bool {
+		return r.outcome != denied
+	})
+}
+
+// refreshIssue replaces the issue or pull request of a comment event with its current state,
+// since actors read labels, assignees and state from the event rather than from the API.
+func refreshIssue(ctx context.Context, ghClient *github.Client, event any) (any, error) {
+	switch evt := event.(type) {
+	case github.IssueCommentEvent:
+		owner, repoName := actors.GetOwnerRepo(evt.GetRepo().GetFullName())
+		issue, _, err := ghClient.Issues.Get(ctx, owner, repoName, evt.GetIssue().GetNumber())
+		if err != nil {
+			return nil, err
+		}
+		evt.Issue = issue
+		return evt, nil
+	case github.PullRequestReviewCommentEvent:
+		owner, repoName := actors.GetOwnerRepo(evt.GetRepo().GetFullName())
+		pr, _, err := ghClient.PullRequests.Get(ctx, owner, repoName, evt.GetPullRequest().GetNumber())
+		if err != nil {
+			return nil, err
+		}
+		evt.PullRequest = pr
+		return evt, nil
+	default:
+		return event, nil
+	}
+}
+
+// commandSource returns who issued the command carried by the event and on which issue or pull request,
+// ok is false for events which are not triggered by a command.
+func commandSource(event any) (login, fullName string, number int, ok bool) {
+	switch evt := event.(type) {
+	case github.IssueCommentEvent:
+		return evt.GetComment().GetUser().GetLogin(), evt.GetRepo().GetFullName(), evt.GetIssue().GetNumber(), true
+	case github.PullRequestReviewEvent:
+		return evt.GetReview().GetUser().GetLogin(), evt.GetRepo().GetFullName(), evt.GetPullRequest().GetNumber(), true
+	case github.PullRequestReviewCommentEvent:
+		return evt.GetComment().GetUser().GetLogin(), evt.GetRepo().GetFullName(), evt.GetPullRequest().GetNumber(), true
+	default:
+		return "", "", 0, false
+	}
+}
+
+// loadConfig loads the repository configuration from the checked-out workspace,
+// and falls back to the default branch through the contents API when the workspace is not available.
+func loadConfig(ctx context.Context, ghClient *github.Client, ghRepo, ghWorkspace, configPath string) (*config.Config, error) {
+	if len(configPath) == 0 {
+		configPath = config.DefaultPath
+	}
+
+	var (
+		cfg *config.Config
+		err error
+	)
+	workspacePath := filepath.Join(ghWorkspace, configPath)
+	if _, statErr := os.Stat(workspacePath); len(ghWorkspace) != 0 && statErr == nil {
+		logger.Infof("load configuration from workspace file '%s'", workspacePath)
+		cfg, err = config.Load(workspacePath)
+	} else {
+		cfg, err = fetchConfig(ctx, ghClient, ghRepo, configPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(registeredActors(), registeredCommands(cfg)); err != nil {
+		return nil, fmt.Errorf("invalid configuration '%s': %w", configPath, err)
+	}
+
+	return cfg, nil
+}
+
+func fetchConfig(ctx context.Context, ghClient *github.Client, ghRepo, configPath string) (*config.Config, error) {
+	if len(ghRepo) == 0 {
+		logger.Infof("no repository provided, use the default configuration")
+		return config.Default(), nil
+	}
+
+	content, found, err := actors.GetFileContent(ctx, ghClient, ghRepo, configPath, "")
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("fetch configuration '%s': %w", configPath, err)
+	case !found:
+		logger.Infof("configuration '%s' not found in %s, use the default configuration", configPath, ghRepo)
+		return config.Default(), nil
+	default:
+		logger.Infof("load configuration '%s' from %s", configPath, ghRepo)
+		return config.Parse(content)
+	}
 }
 
 func readGitHubEvent(ghEventPath string) ([]byte, error) {
@@ -106,21 +510,94 @@ func readGitHubEvent(ghEventPath string) ([]byte, error) {
 	return eventBytes, nil
 }
 
-func InitGitHubClient(ghToken string) (*github.Client, error) {
-	if len(ghToken) == 0 {
-		return nil, errors.New("empty github token")
+// credentialsFromEnv reads the credentials from the inputs of the action
+func credentialsFromEnv() (Credentials, error) {
+	creds := Credentials{
+		Token:         os.Getenv("token"),
+		AppPrivateKey: []byte(os.Getenv("app_private_key")),
+	}
+
+	var err error
+	if creds.AppID, err = parseID(os.Getenv("app_id")); err != nil {
+		return creds, fmt.Errorf("invalid app id: %w", err)
+	}
+	if creds.AppInstallationID, err = parseID(os.Getenv("app_installation_id")); err != nil {
+		return creds, fmt.Errorf("invalid app installation id: %w", err)
 	}
 
-	oauthConfig := oauth2.Config{
-		Endpoint: oauthGh.Endpoint,
+	return creds, nil
+}
+
+func parseID(s string) (int64, error) {
+	if len(s) == 0 {
+		return 0, nil
 	}
-	oClient := oauthConfig.Client(
-		context.Background(),
-		&oauth2.Token{AccessToken: ghToken},
+
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// parseTimeout parses a duration like "90s" or "5m", "0" disables the timeout
+func parseTimeout(s string, defaultTimeout time.Duration) (time.Duration, error) {
+	if len(s) == 0 {
+		return defaultTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("negative duration %s", s)
+	}
+
+	return timeout, nil
+}
+
+// newGitHubClient initializes the GitHub client of the endpoint, the repository is used to discover
+// the installation of the GitHub App. In dry-run mode requests which would mutate the repository are never sent
+// and the token may be empty, in which case the API is accessed anonymously.
+// Each attempt of a request is bounded by the call timeout on its own, so that a retry gets a fresh deadline,
+// and a timed-out idempotent request is retried while a timed-out mutation is not.
+func newGitHubClient(creds Credentials, endpoint Endpoint, repo string, dryRun bool, callTimeout time.Duration) (*github.Client, error) {
+	var (
+		base      = ratelimit.New(&timeoutTransport{base: http.DefaultTransport, timeout: callTimeout}, logger)
+		transport http.RoundTripper
 	)
-	ghClient := github.NewClient(oClient)
+	switch {
+	case creds.AppID != 0:
+		baseURL, err := endpoint.baseURL()
+		if err != nil {
+			return nil, err
+		}
+		appTransport, err := githubapp.NewTransport(base, githubapp.Config{
+			AppID:          creds.AppID,
+			PrivateKey:     creds.AppPrivateKey,
+			InstallationID: creds.AppInstallationID,
+			Repo:           repo,
+			BaseURL:        baseURL,
+		})
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("authenticate as GitHub App %d", creds.AppID)
+		transport = appTransport
+	case len(creds.Token) != 0:
+		transport = &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: creds.Token}),
+			Base:   base,
+		}
+	case !dryRun:
+		return nil, errors.New("empty github token")
+	default:
+		transport = base
+	}
 
-	return ghClient, nil
+	if dryRun {
+		logger.Infof("dry-run mode is enabled, mutations are logged instead of being sent")
+		transport = &dryRunTransport{base: transport}
+	}
+
+	return endpoint.apply(github.NewClient(&http.Client{Transport: transport}))
 }
 
 func copyEvent(src *actors.GenericEvent) (*actors.GenericEvent, error) {
@@ -133,11 +610,3 @@ func copyEvent(src *actors.GenericEvent) (*actors.GenericEvent, error) {
 
 	return &dst, nil
 }
-
-func exit(format string, err ...any) {
-	// avoid losing the call stack information
-	logger.CallerSkip += 1
-
-	logger.Errorf(format, err...)
-	os.Exit(1)
-}
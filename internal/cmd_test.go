@@ -1,39 +1,515 @@
 package internal
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/go-github/v72/github"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
+	"github.com/ShyunnY/actbot/internal/permission"
 )
 
-func TestInitGitHubClient(t *testing.T) {
+func TestNewGitHubClient(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/repos/foo/bar/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(github.Issue{Title: github.Ptr(r.Header.Get("Authorization"))}))
+	})
+	var slowCalls int
+	mux.HandleFunc("GET /api/v3/repos/foo/bar/issues/2", func(w http.ResponseWriter, r *http.Request) {
+		// only the first attempt hangs beyond the call timeout
+		if slowCalls++; slowCalls == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"number":2}`)
+	})
+	mux.HandleFunc("POST /api/v3/repos/foo/bar/issues/2/comments", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	endpoint := Endpoint{APIURL: server.URL}
+
+	t.Run("token is required unless in dry-run mode", func(t *testing.T) {
+		_, err := newGitHubClient(Credentials{}, endpoint, "foo/bar", false, defaultCallTimeout)
+		assert.EqualError(t, err, "empty github token")
+	})
+
+	t.Run("anonymous access in dry-run mode does not send mutations", func(t *testing.T) {
+		mu.Lock()
+		calls = nil
+		mu.Unlock()
+		ghClient, err := newGitHubClient(Credentials{}, endpoint, "foo/bar", true, defaultCallTimeout)
+		require.NoError(t, err)
+
+		issue, _, err := ghClient.Issues.Get(context.Background(), "foo", "bar", 1)
+		require.NoError(t, err)
+		assert.Empty(t, issue.GetTitle())
+		_, _, err = ghClient.Issues.CreateComment(context.Background(), "foo", "bar", 1, &github.IssueComment{Body: github.Ptr("hi")})
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"GET /api/v3/repos/foo/bar/issues/1"}, calls)
+	})
+
+	t.Run("token is sent as bearer", func(t *testing.T) {
+		ghClient, err := newGitHubClient(Credentials{Token: "foo"}, endpoint, "foo/bar", false, defaultCallTimeout)
+		require.NoError(t, err)
+
+		issue, _, err := ghClient.Issues.Get(context.Background(), "foo", "bar", 1)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer foo", issue.GetTitle())
+	})
+
+	t.Run("timed-out calls are retried with a fresh deadline unless they are mutations", func(t *testing.T) {
+		ghClient, err := newGitHubClient(Credentials{Token: "foo"}, endpoint, "foo/bar", false, 50*time.Millisecond)
+		require.NoError(t, err)
+
+		issue, _, err := ghClient.Issues.Get(context.Background(), "foo", "bar", 2)
+		require.NoError(t, err)
+		assert.Equal(t, 2, issue.GetNumber())
+
+		_, _, err = ghClient.Issues.CreateComment(context.Background(), "foo", "bar", 2, &github.IssueComment{Body: github.Ptr("hi")})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestEventDecoders(t *testing.T) {
+	cases := []struct {
+		eventType GitHubEventType
+		payload   string
+		expect    any
+	}{
+		{
+			eventType: IssueComment,
+			payload:   `{"action":"created"}`,
+			expect:    github.IssueCommentEvent{Action: github.Ptr("created")},
+		},
+		{
+			eventType: PullRequest,
+			payload:   `{"action":"synchronize","number":1}`,
+			expect:    github.PullRequestEvent{Action: github.Ptr("synchronize"), Number: github.Ptr(1)},
+		},
+		{
+			eventType: PullRequestTarget,
+			payload:   `{"action":"opened"}`,
+			expect:    github.PullRequestEvent{Action: github.Ptr("opened")},
+		},
+		{
+			eventType: Issues,
+			payload:   `{"action":"assigned"}`,
+			expect:    github.IssuesEvent{Action: github.Ptr("assigned")},
+		},
+		{
+			eventType: PullRequestReview,
+			payload:   `{"action":"submitted","review":{"state":"approved"}}`,
+			expect:    github.PullRequestReviewEvent{Action: github.Ptr("submitted"), Review: &github.PullRequestReview{State: github.Ptr("approved")}},
+		},
+		{
+			eventType: PullRequestReviewComment,
+			payload:   `{"action":"created"}`,
+			expect:    github.PullRequestReviewCommentEvent{Action: github.Ptr("created")},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.eventType), func(t *testing.T) {
+			decode, ok := eventDecoders[tc.eventType]
+			require.True(t, ok)
+			assert.Contains(t, actorMap, tc.eventType)
+
+			evt, err := decode([]byte(tc.payload))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, evt)
+		})
+	}
+}
+
+func TestDispatchUnsupportedEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0o600))
+
+	err := dispatch(context.Background(), "push", path, nil, config.Default())
+	assert.ErrorContains(t, err, "unsupported github event 'push'")
+}
+
+func TestDispatchCommandsInOrder(t *testing.T) {
+	var (
+		calls   []string
+		labels  = map[string]bool{}
+		replies []string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/foo/bar/issues/1/labels":
+			var names []string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&names))
+			for _, name := range names {
+				labels[name] = true
+			}
+			_, _ = fmt.Fprint(w, `[]`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/foo/bar/issues/1":
+			issueLabels := make([]*github.Label, 0, len(labels))
+			for name := range labels {
+				issueLabels = append(issueLabels, &github.Label{Name: github.Ptr(name)})
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(github.Issue{
+				Number:           github.Ptr(1),
+				User:             &github.User{Login: github.Ptr("alice")},
+				Labels:           issueLabels,
+				PullRequestLinks: &github.PullRequestLinks{},
+			}))
+		case r.Method == http.MethodDelete && r.URL.Path == "/repos/foo/bar/issues/1/labels/lgtm":
+			delete(labels, "lgtm")
+			_, _ = fmt.Fprint(w, `[]`)
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/foo/bar/issues/1/comments":
+			var comment github.IssueComment
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&comment))
+			replies = append(replies, comment.GetBody())
+			_, _ = fmt.Fprint(w, `{}`)
+		default:
+			_, _ = fmt.Fprint(w, `{}`)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ghClient := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	ghClient.BaseURL = baseURL
+
+	payload, err := json.Marshal(github.IssueCommentEvent{
+		Action: github.Ptr("created"),
+		Repo:   &github.Repository{FullName: github.Ptr("foo/bar")},
+		Issue: &github.Issue{
+			Number:           github.Ptr(1),
+			User:             &github.User{Login: github.Ptr("alice")},
+			PullRequestLinks: &github.PullRequestLinks{},
+		},
+		Comment: &github.IssueComment{
+			ID:   github.Ptr[int64](10),
+			User: &github.User{Login: github.Ptr("bob")},
+			Body: github.Ptr("/lgtm\n/cc @carol\n/lgtm cancel\n/foo"),
+		},
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "event.json")
+	require.NoError(t, os.WriteFile(path, payload, 0o600))
+
+	cfg := config.Default()
+	cfg.Permissions["cc"] = config.Permission{Users: []string{"alice"}}
+	require.NoError(t, dispatch(context.Background(), string(IssueComment), path, ghClient, cfg))
+
+	// the lgtm label is added and then removed again since the commands run in textual order
+	assert.Equal(t, []string{
+		"POST /repos/foo/bar/issues/1/labels",
+		"POST /repos/foo/bar/issues/comments/10/reactions",
+		// the issue is refreshed after the handled command, but not after the denied one
+		"GET /repos/foo/bar/issues/1",
+		"GET /repos/foo/bar/issues/1",
+		"DELETE /repos/foo/bar/issues/1/labels/lgtm",
+		"GET /repos/foo/bar/issues/1",
+		"POST /repos/foo/bar/issues/1/comments",
+	}, calls)
+	assert.Empty(t, labels)
+
+	// the denied command is reported in the summary instead of a separate reply
+	require.Len(t, replies, 1)
+	assert.Equal(t, strings.Join([]string{
+		"@bob Results of the commands in your comment:",
+		"",
+		"| Command | Result |",
+		"|---------|--------|",
+		"| `/lgtm` | :white_check_mark: handled by lgtm |",
+		"| `/cc @carol` | :no_entry: denied, cc requires being one of the users [alice] |",
+		"| `/lgtm cancel` | :white_check_mark: handled by lgtm |",
+		"| `/foo` | :grey_question: ignored, no actor handles this command |",
+		"",
+	}, "\n"), replies[0])
+}
+
+func TestDispatchContinuesAfterFailure(t *testing.T) {
+	cases := []struct {
+		caseName       string
+		body           string
+		reportFailures bool
+
+		expectCalls   []string
+		expectReplies []string
+	}{
+		{
+			caseName:       "failure of a single command is replied",
+			body:           "/lgtm",
+			reportFailures: true,
+			expectCalls: []string{
+				"POST /repos/foo/bar/issues/1/labels",
+				"POST /repos/foo/bar/issues/1/comments",
+			},
+			expectReplies: []string{strings.Join([]string{
+				"@bob Your command could not be handled completely, the following actors failed:",
+				"",
+				"- lgtm: POST {server}/repos/foo/bar/issues/1/labels: 500  []",
+				"",
+			}, "\n")},
+		},
+		{
+			caseName:       "failure report is disabled",
+			body:           "/lgtm",
+			reportFailures: false,
+			expectCalls: []string{
+				"POST /repos/foo/bar/issues/1/labels",
+			},
+		},
+		{
+			caseName:       "later commands run after a failure",
+			body:           "/lgtm\n/cc @carol",
+			reportFailures: false,
+			expectCalls: []string{
+				"POST /repos/foo/bar/issues/1/labels",
+				"GET /repos/foo/bar/issues/1",
+				"POST /repos/foo/bar/pulls/1/requested_reviewers",
+				"POST /repos/foo/bar/issues/1/comments",
+			},
+			expectReplies: []string{strings.Join([]string{
+				"@bob Results of the commands in your comment:",
+				"",
+				"| Command | Result |",
+				"|---------|--------|",
+				"| `/lgtm` | :x: lgtm failed: POST {server}/repos/foo/bar/issues/1/labels: 500  [] |",
+				"| `/cc @carol` | :white_check_mark: handled by cc |",
+				"",
+			}, "\n")},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			var (
+				calls   []string
+				replies []string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, r.Method+" "+r.URL.Path)
+				switch {
+				case r.Method == http.MethodPost && r.URL.Path == "/repos/foo/bar/issues/1/labels":
+					w.WriteHeader(http.StatusInternalServerError)
+				case r.Method == http.MethodGet && r.URL.Path == "/repos/foo/bar/issues/1":
+					_, _ = fmt.Fprint(w, `{"number":1,"user":{"login":"alice"},"pull_request":{}}`)
+				case r.Method == http.MethodPost && r.URL.Path == "/repos/foo/bar/issues/1/comments":
+					var comment github.IssueComment
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&comment))
+					replies = append(replies, comment.GetBody())
+					_, _ = fmt.Fprint(w, `{}`)
+				default:
+					_, _ = fmt.Fprint(w, `{}`)
+				}
+			}))
+			defer server.Close()
+
+			ghClient := github.NewClient(nil)
+			baseURL, err := url.Parse(server.URL + "/")
+			require.NoError(t, err)
+			ghClient.BaseURL = baseURL
+
+			payload, err := json.Marshal(github.IssueCommentEvent{
+				Action: github.Ptr("created"),
+				Repo:   &github.Repository{FullName: github.Ptr("foo/bar")},
+				Issue: &github.Issue{
+					Number:           github.Ptr(1),
+					User:             &github.User{Login: github.Ptr("alice")},
+					PullRequestLinks: &github.PullRequestLinks{},
+				},
+				Comment: &github.IssueComment{
+					ID:   github.Ptr[int64](10),
+					User: &github.User{Login: github.Ptr("bob")},
+					Body: github.Ptr(tc.body),
+				},
+			})
+			require.NoError(t, err)
+
+			cfg := config.Default()
+			cfg.ReportFailures = tc.reportFailures
+			err = dispatchPayload(context.Background(), string(IssueComment), payload, ghClient, cfg)
+			assert.ErrorContains(t, err, "actor lgtm handle by err")
+
+			assert.Equal(t, tc.expectCalls, calls)
+			for i := range tc.expectReplies {
+				tc.expectReplies[i] = strings.ReplaceAll(tc.expectReplies[i], "{server}", server.URL)
+			}
+			assert.Equal(t, tc.expectReplies, replies)
+		})
+	}
+}
+
+func TestAuthorizeDenialReply(t *testing.T) {
+	var (
+		repo      = &github.Repository{FullName: github.Ptr("foo/bar")}
+		bob       = &github.User{Login: github.Ptr("bob")}
+		commentOf = func(body string) github.IssueCommentEvent {
+			return github.IssueCommentEvent{
+				Repo:    repo,
+				Issue:   &github.Issue{Number: github.Ptr(1)},
+				Comment: &github.IssueComment{User: bob, Body: github.Ptr(body)},
+			}
+		}
+	)
+
 	cases := []struct {
 		caseName string
-		token    string
-		expect   bool
+		actor    string
+		// key the key of the requirement in the configuration, the actor when it is empty
+		key    string
+		event  any
+		expect string
 	}{
 		{
-			caseName: "Provide github token to initialize the github client",
-			token:    "fake token",
-			expect:   true,
+			caseName: "the command written by the user is named instead of the actor",
+			actor:    "retest",
+			key:      "test",
+			event:    commentOf("/test e2e"),
+			expect:   "@bob You are not allowed to use the '/test' command, it requires being one of the users [alice]",
 		},
 		{
-			caseName: "Provide empty github token to initialize the github client",
-			token:    "",
-			expect:   false,
+			caseName: "a label shortcut is named as written",
+			actor:    "label",
+			event:    commentOf("/kind bug"),
+			expect:   "@bob You are not allowed to use the '/kind' command, it requires being one of the users [alice]",
+		},
+		{
+			caseName: "a review does not name any command",
+			actor:    "lgtm",
+			event: github.PullRequestReviewEvent{
+				Repo:        repo,
+				PullRequest: &github.PullRequest{Number: github.Ptr(1)},
+				Review:      &github.PullRequestReview{User: bob, State: github.Ptr("approved")},
+			},
+			expect: "@bob You are not allowed to trigger the 'lgtm' actor, it requires being one of the users [alice]",
 		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.caseName, func(t *testing.T) {
-			ghClient, err := InitGitHubClient(tc.token)
-			if tc.expect {
-				assert.NotNil(t, ghClient)
-				assert.NoError(t, err)
-			} else {
-				assert.Nil(t, ghClient)
-				assert.Error(t, err)
+			server := githubtest.NewServer(t)
+			issue := server.Repo("foo/bar").AddPullRequest(1, "alice", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+			cfg := config.Default()
+			key := tc.key
+			if len(key) == 0 {
+				key = tc.actor
+			}
+			cfg.Permissions[key] = config.Permission{Users: []string{"alice"}}
+
+			var command string
+			if body, _, ok := commentBody(tc.event); ok {
+				command = commandName(commands.Parse(body))
 			}
+			checker := permission.NewChecker(server.Client(), "foo/bar")
+			allowed, err := authorize(context.Background(), server.Client(), checker, cfg, tc.actor, command, tc.event, true)
+			require.NoError(t, err)
+			assert.False(t, allowed)
+			assert.Equal(t, []string{tc.expect}, issue.CommentBodies())
 		})
 	}
 }
+
+func TestDispatchAborted(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		_, _ = fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	ghClient := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	ghClient.BaseURL = baseURL
+
+	payload, err := json.Marshal(github.IssueCommentEvent{
+		Action: github.Ptr("created"),
+		Repo:   &github.Repository{FullName: github.Ptr("foo/bar")},
+		Issue: &github.Issue{
+			Number:           github.Ptr(1),
+			PullRequestLinks: &github.PullRequestLinks{},
+		},
+		Comment: &github.IssueComment{
+			ID:   github.Ptr[int64](10),
+			User: &github.User{Login: github.Ptr("bob")},
+			Body: github.Ptr("/lgtm\n/cc @carol"),
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = dispatchPayload(ctx, string(IssueComment), payload, ghClient, config.Default())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorContains(t, err, "command '/lgtm' aborted")
+	assert.Empty(t, calls)
+}
+
+func TestParseTimeout(t *testing.T) {
+	timeout, err := parseTimeout("", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, timeout)
+
+	timeout, err = parseTimeout("90s", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+
+	timeout, err = parseTimeout("0", time.Minute)
+	require.NoError(t, err)
+	assert.Zero(t, timeout)
+
+	_, err = parseTimeout("soon", time.Minute)
+	assert.Error(t, err)
+	_, err = parseTimeout("-1m", time.Minute)
+	assert.Error(t, err)
+}
+
+func TestCredentialsFromEnv(t *testing.T) {
+	t.Setenv("token", "foo")
+	t.Setenv("app_id", "42")
+	t.Setenv("app_private_key", "pem")
+	t.Setenv("app_installation_id", "")
+
+	creds, err := credentialsFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{Token: "foo", AppID: 42, AppPrivateKey: []byte("pem")}, creds)
+
+	t.Setenv("app_installation_id", "seven")
+	_, err = credentialsFromEnv()
+	assert.ErrorContains(t, err, "invalid app installation id")
+}
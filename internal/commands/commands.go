@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// CancelArg the argument withdrawing an instruction, e.g. "/lgtm cancel"
+	CancelArg = "cancel"
+
+	commandPrefix = "/"
+	flagPrefix    = "--"
+	mentionPrefix = "@"
+	quotePrefix   = ">"
+
+	// codeIndent the indentation of an indented code block, a tab counts up to the next multiple of 4 columns
+	codeIndent = 4
+)
+
+var (
+	nameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+	fenceMarkers = []string{"```", "~~~"}
+)
+
+// Command a slash command written in a comment, e.g. `/label kind/bug "help wanted" --force`
+type Command struct {
+	// Name the lowercased name of the command without the leading slash
+	Name string
+
+	// Args the positional arguments of the command, quotes are removed
+	Args []string
+
+	// Flags the flags of the command, "--name=value" or "--name" with an empty value
+	Flags map[string]string
+
+	// Line the 1-based line number of the command in the comment
+	Line int
+
+	// Raw the trimmed line which the command is parsed from
+	Raw string
+}
+
+// Mentions returns the users mentioned in the arguments without the leading "@"
+func (c Command) Mentions() []string {
+	var mentions []string
+	for _, arg := range c.Args {
+		if user := strings.TrimPrefix(arg, mentionPrefix); len(user) != len(arg) && len(user) != 0 {
+			mentions = append(mentions, user)
+		}
+	}
+
+	return mentions
+}
+
+// HasFlag reports whether the flag has been provided
+func (c Command) HasFlag(name string) bool {
+	_, ok := c.Flags[name]
+	return ok
+}
+
+// Parse extracts the commands of a comment in textual order.
+// A command must be the first word of a line, lines inside fenced or indented code blocks and quoted replies are skipped.
+func Parse(body string) []Command {
+	var (
+		cmds  []Command
+		fence string
+	)
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		// an indented line is code rather than a command or a fence marker, e.g. an example in the description
+		if indentation(line) >= codeIndent {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+
+		if marker := fenceMarker(trimmed); len(marker) != 0 {
+			switch {
+			case len(fence) == 0:
+				fence = marker
+			case marker == fence:
+				fence = ""
+			}
+			continue
+		}
+		if len(fence) != 0 || strings.HasPrefix(trimmed, quotePrefix) {
+			continue
+		}
+
+		cmd, ok := parseLine(trimmed)
+		if !ok {
+			continue
+		}
+		cmd.Line = i + 1
+		cmds = append(cmds, cmd)
+	}
+
+	return cmds
+}
+
+// Filter returns the commands with any of the names, preserving their order
+func Filter(cmds []Command, names ...string) []Command {
+	var ret []Command
+	for _, cmd := range cmds {
+		for _, name := range names {
+			if cmd.Name == name {
+				ret = append(ret, cmd)
+				break
+			}
+		}
+	}
+
+	return ret
+}
+
+// MatchInstruction reports whether the commands contain a "/<name>" or "/<name> cancel" instruction,
+// the last instruction wins when the commands contain both of them.
+func MatchInstruction(cmds []Command, name string) (cancel, ok bool) {
+	for _, cmd := range Filter(cmds, name) {
+		switch {
+		case len(cmd.Args) == 0:
+			cancel, ok = false, true
+		case len(cmd.Args) == 1 && strings.EqualFold(cmd.Args[0], CancelArg):
+			cancel, ok = true, true
+		}
+	}
+
+	return cancel, ok
+}
+
+func fenceMarker(line string) string {
+	for _, marker := range fenceMarkers {
+		if strings.HasPrefix(line, marker) {
+			return marker
+		}
+	}
+
+	return ""
+}
+
+// indentation returns the columns of the leading whitespace of the line
+func indentation(line string) int {
+	columns := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			columns++
+		case '\t':
+			columns += codeIndent - columns%codeIndent
+		default:
+			return columns
+		}
+	}
+
+	return columns
+}
+
+func parseLine(line string) (Command, bool) {
+	if !strings.HasPrefix(line, commandPrefix) {
+		return Command{}, false
+	}
+
+	text := strings.TrimPrefix(line, commandPrefix)
+	name, rest := text, ""
+	if idx := strings.IndexFunc(text, unicode.IsSpace); idx >= 0 {
+		name, rest = text[:idx], text[idx:]
+	}
+	if !nameRegexp.MatchString(name) {
+		return Command{}, false
+	}
+
+	cmd := Command{
+		Name: strings.ToLower(name),
+		Raw:  line,
+	}
+	for _, tok := range tokenize(rest) {
+		if !tok.quoted && strings.HasPrefix(tok.value, flagPrefix) && len(tok.value) > len(flagPrefix) {
+			flagName, flagValue, _ := strings.Cut(strings.TrimPrefix(tok.value, flagPrefix), "=")
+			if cmd.Flags == nil {
+				cmd.Flags = make(map[string]string)
+			}
+			cmd.Flags[flagName] = flagValue
+			continue
+		}
+		cmd.Args = append(cmd.Args, tok.value)
+	}
+
+	return cmd, true
+}
+
+type token struct {
+	value string
+	// quoted whether the token was quoted, quoted tokens are never treated as flags
+	quoted bool
+}
+
+// tokenize splits the text by whitespace, a token starting with a single or double quote extends to the closing quote,
+// so that "help wanted" is a single token while an apostrophe inside a word (e.g. won't) is kept as is.
+// A backslash escapes the next character inside double quotes, an unterminated quote extends to the end.
+func tokenize(text string) []token {
+	var (
+		tokens  []token
+		current strings.Builder
+		inToken bool
+		quoted  bool
+		quote   rune
+		escaped bool
+	)
+	flush := func() {
+		if inToken {
+			tokens = append(tokens, token{value: current.String(), quoted: quoted})
+		}
+		current.Reset()
+		inToken, quoted = false, false
+	}
+
+	for _, r := range text {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote != 0:
+			switch {
+			case r == quote:
+				quote = 0
+			case r == '\\' && quote == '"':
+				escaped = true
+			default:
+				current.WriteRune(r)
+			}
+		case (r == '"' || r == '\'') && !inToken:
+			quote = r
+			inToken, quoted = true, true
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			current.WriteRune(r)
+			inToken = true
+		}
+	}
+	flush()
+
+	return tokens
+}
@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		caseName string
+		body     string
+		expect   []Command
+	}{
+		{
+			caseName: "single command without arguments",
+			body:     "/retest",
+			expect: []Command{
+				{Name: "retest", Line: 1, Raw: "/retest"},
+			},
+		},
+		{
+			caseName: "command names are case insensitive",
+			body:     "/LGTM",
+			expect: []Command{
+				{Name: "lgtm", Line: 1, Raw: "/LGTM"},
+			},
+		},
+		{
+			caseName: "commands on any line with surrounding text",
+			body:     "Thanks for the fix!\n  /assign\n\n   /label kind/bug\t\nsome trailing words",
+			expect: []Command{
+				{Name: "assign", Line: 2, Raw: "/assign"},
+				{Name: "label", Args: []string{"kind/bug"}, Line: 4, Raw: "/label kind/bug"},
+			},
+		},
+		{
+			caseName: "quoted arguments, mentions and flags",
+			body:     `/label "help wanted" 'good first issue' won't --force --reason=dup "--not-a-flag" @foo`,
+			expect: []Command{
+				{
+					Name:  "label",
+					Args:  []string{"help wanted", "good first issue", "won't", "--not-a-flag", "@foo"},
+					Flags: map[string]string{"force": "", "reason": "dup"},
+					Line:  1,
+					Raw:   `/label "help wanted" 'good first issue' won't --force --reason=dup "--not-a-flag" @foo`,
+				},
+			},
+		},
+		{
+			caseName: "escaped and unterminated quotes",
+			body:     `/label "say \"hi\"" "never closed`,
+			expect: []Command{
+				{Name: "label", Args: []string{`say "hi"`, "never closed"}, Line: 1, Raw: `/label "say \"hi\"" "never closed`},
+			},
+		},
+		{
+			caseName: "fenced code blocks are skipped",
+			body:     "```\n/retest\n~~~\n/lgtm\n```\n/cc @foo\n~~~sh\n/assign\n~~~",
+			expect: []Command{
+				{Name: "cc", Args: []string{"@foo"}, Line: 6, Raw: "/cc @foo"},
+			},
+		},
+		{
+			caseName: "indented code blocks are skipped",
+			body:     "Example:\n\n    /close\n\t/lgtm\n  \t/hold\n   ```\n/retest\n   ```\n    ```\n/cc",
+			expect: []Command{
+				{Name: "cc", Line: 10, Raw: "/cc"},
+			},
+		},
+		{
+			caseName: "quoted replies are skipped",
+			body:     "> /approve\n>/lgtm\n/hold",
+			expect: []Command{
+				{Name: "hold", Line: 3, Raw: "/hold"},
+			},
+		},
+		{
+			caseName: "paths and plain text are not commands",
+			body:     "/usr/bin/env\n/ foo\n//comment\nfoo /assign\r\n/retest\r\n",
+			expect: []Command{
+				{Name: "retest", Line: 5, Raw: "/retest"},
+			},
+		},
+		{
+			caseName: "empty body",
+			body:     "",
+			expect:   nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			assert.Equal(t, tc.expect, Parse(tc.body))
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	cmds := Parse("/assign\n/label foo\n/unassign\n/cc @bar")
+
+	filtered := Filter(cmds, "unassign", "assign")
+	assert.Len(t, filtered, 2)
+	assert.Equal(t, "assign", filtered[0].Name)
+	assert.Equal(t, "unassign", filtered[1].Name)
+
+	assert.Empty(t, Filter(cmds, "retest"))
+}
+
+func TestMatchInstruction(t *testing.T) {
+	cases := []struct {
+		caseName string
+		comment  string
+		expect   bool
+		cancel   bool
+	}{
+		{
+			caseName: "match the instruction",
+			comment:  "/lgtm",
+			expect:   true,
+		},
+		{
+			caseName: "match the cancel instruction",
+			comment:  "/lgtm CANCEL",
+			expect:   true,
+			cancel:   true,
+		},
+		{
+			caseName: "match the instruction in multi line comment",
+			comment:  "looks good to me\n/lgtm\n",
+			expect:   true,
+		},
+		{
+			caseName: "the last instruction wins",
+			comment:  "/lgtm cancel\n/LGTM",
+			expect:   true,
+		},
+		{
+			caseName: "unmatched instructions",
+			comment:  "/lgtm1\n/hold",
+			expect:   false,
+		},
+		{
+			caseName: "unmatched instructions with unknown arguments",
+			comment:  "/lgtm foo",
+			expect:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			cancel, ok := MatchInstruction(Parse(tc.comment), "lgtm")
+			assert.Equal(t, tc.expect, ok)
+			assert.Equal(t, tc.cancel, cancel)
+		})
+	}
+}
+
+func TestMentions(t *testing.T) {
+	cmd := Parse("/cc @foo bar @ @baz")[0]
+	assert.Equal(t, []string{"foo", "baz"}, cmd.Mentions())
+	assert.False(t, cmd.HasFlag("force"))
+}
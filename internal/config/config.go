@@ -0,0 +1,451 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+)
+
+const (
+	// DefaultPath the path of the configuration file relative to the repository root
+	DefaultPath = ".github/actbot.yaml"
+
+	// CollaboratorRole everyone has read access to public repositories, triage is the lowest role only collaborators have
+	CollaboratorRole = "triage"
+)
+
+var (
+	// validReactions the reactions which are accepted by the GitHub API
+	validReactions = sets.New("+1", "-1", "laugh", "confused", "heart", "hooray", "rocket", "eyes")
+
+	// Roles the repository roles ordered from the lowest to the highest permission
+	Roles = []string{"none", "read", "triage", "write", "maintain", "admin"}
+
+	// validConclusions the conclusions a completed check run may have
+	validConclusions = sets.New("action_required", "cancelled", "failure", "neutral", "skipped", "stale", "startup_failure", "success", "timed_out")
+)
+
+// Config represents the repository configuration of actbot
+type Config struct {
+	// Actors enables or disables individual actors, keyed by actor name
+	Actors map[string]ActorConfig `yaml:"actors"`
+
+	// Labels overrides the names of labels maintained by actors
+	Labels Labels `yaml:"labels"`
+
+	// Reactions overrides the reactions added to instruction comments
+	Reactions Reactions `yaml:"reactions"`
+
+	// Replies overrides the reply templates of actors
+	Replies Replies `yaml:"replies"`
+
+	// Permissions restricts who is allowed to use a command, keyed by command name or by actor name.
+	// The requirement of a command takes precedence over the one of its actor, which covers all of its commands.
+	// Commands without a requirement can be used by anyone who is able to comment.
+	Permissions map[string]Permission `yaml:"permissions"`
+
+	// Retest tunes which check runs are rerun by /retest and how
+	Retest Retest `yaml:"retest"`
+
+	// Hold configures the check run reflecting whether a pull request is held by /hold
+	Hold Hold `yaml:"hold"`
+
+	// Assign configures where /assign and /unassign can be used
+	Assign Assign `yaml:"assign"`
+
+	// Label configures the label policies enforced by /label, /unlabel and their shortcuts
+	Label Label `yaml:"label"`
+
+	// ReportFailures replies to the command with the actors which failed to handle it,
+	// the failures are always reported in the summary of a comment containing several commands
+	ReportFailures bool `yaml:"reportFailures"`
+}
+
+type Retest struct {
+	// Conclusions the conclusions of the check runs rerun by /retest
+	Conclusions []string `yaml:"conclusions"`
+
+	// WorkflowRuns reruns the failed jobs of the workflow runs owning the check runs at once,
+	// instead of rerunning each job on its own
+	WorkflowRuns bool `yaml:"workflowRuns"`
+}
+
+type Hold struct {
+	// CheckName the name of the check run which fails while the pull request is held,
+	// so that branch protection can require it. No check run is maintained when it is empty.
+	CheckName string `yaml:"checkName"`
+}
+
+type Assign struct {
+	// PullRequests allows /assign and /unassign on pull requests, e.g. to pick a shepherd.
+	// The help wanted label is only maintained for issues.
+	PullRequests bool `yaml:"pullRequests"`
+}
+
+type Label struct {
+	// Restricted the labels which can only be added or removed by the users fulfilling the permission,
+	// keyed by label name. A key ending with "/*" restricts every label of the namespace, e.g. "priority/*".
+	Restricted map[string]Permission `yaml:"restricted"`
+
+	// Shortcuts the namespaces which can be used as commands, e.g. "/kind bug" adds the "kind/bug" label
+	Shortcuts []string `yaml:"shortcuts"`
+
+	// Exclusive the namespaces of which an issue has one label at most,
+	// adding "priority/p0" removes "priority/p2" when "priority" is exclusive
+	Exclusive []string `yaml:"exclusive"`
+}
+
+// Restriction returns the permission required to add or remove the label, an exact key wins over a namespace key
+func (l Label) Restriction(label string) Permission {
+	if permission, ok := l.Restricted[label]; ok {
+		return permission
+	}
+	if namespace, _, ok := strings.Cut(label, "/"); ok {
+		return l.Restricted[namespace+"/*"]
+	}
+
+	return Permission{}
+}
+
+type ActorConfig struct {
+	// Enabled whether the actor handles events, actors are enabled by default
+	Enabled *bool `yaml:"enabled"`
+}
+
+// Permission the requirement is fulfilled when any of the conditions is met
+type Permission struct {
+	// Role the minimum repository role of the user, e.g. "triage", "write"
+	Role string `yaml:"role"`
+
+	// Teams the organization teams in "org/team-slug" format, members of any team are allowed
+	Teams []string `yaml:"teams"`
+
+	// Users the logins of users who are allowed
+	Users []string `yaml:"users"`
+}
+
+// IsEmpty reports whether the permission does not restrict anyone
+func (p Permission) IsEmpty() bool {
+	return len(p.Role) == 0 && len(p.Teams) == 0 && len(p.Users) == 0
+}
+
+// String describes the requirement in a human-readable way
+func (p Permission) String() string {
+	var conditions []string
+	if len(p.Role) != 0 {
+		conditions = append(conditions, fmt.Sprintf("'%s' permission on the repository", p.Role))
+	}
+	if len(p.Teams) != 0 {
+		conditions = append(conditions, fmt.Sprintf("membership of one of the teams [%s]", strings.Join(p.Teams, ",")))
+	}
+	if len(p.Users) != 0 {
+		conditions = append(conditions, fmt.Sprintf("being one of the users [%s]", strings.Join(p.Users, ",")))
+	}
+
+	return strings.Join(conditions, " or ")
+}
+
+type Labels struct {
+	HelpWanted string `yaml:"helpWanted"`
+	LGTM       string `yaml:"lgtm"`
+	Approved   string `yaml:"approved"`
+	Hold       string `yaml:"hold"`
+}
+
+type Reactions struct {
+	Commend string `yaml:"commend"`
+	Rocket  string `yaml:"rocket"`
+}
+
+// Replies the reply templates are rendered with text/template, see ReplyData for available fields.
+type Replies struct {
+	AlreadyAssigned     string `yaml:"alreadyAssigned"`
+	NotAssigned         string `yaml:"notAssigned"`
+	NotAssignable       string `yaml:"notAssignable"`
+	ChecksPassed        string `yaml:"checksPassed"`
+	LabelsNotConfigured string `yaml:"labelsNotConfigured"`
+	LabelsNotExist      string `yaml:"labelsNotExist"`
+	LabelsRestricted    string `yaml:"labelsRestricted"`
+	LabelsManaged       string `yaml:"labelsManaged"`
+	SelfLGTM            string `yaml:"selfLGTM"`
+	LGTMRemoved         string `yaml:"lgtmRemoved"`
+	PermissionDenied    string `yaml:"permissionDenied"`
+	CommandsSummary     string `yaml:"commandsSummary"`
+	JobsNotFound        string `yaml:"jobsNotFound"`
+	JobsList            string `yaml:"jobsList"`
+	ActorsFailed        string `yaml:"actorsFailed"`
+}
+
+// ReplyData the data used to render reply templates
+type ReplyData struct {
+	// User login of the user who wrote the instruction
+	User string
+
+	// Label the label involved in the reply
+	Label string
+
+	// Labels comma separated labels involved in the reply
+	Labels string
+
+	// Command the command involved in the reply without the leading slash,
+	// it is empty when the reply is not caused by a command, e.g. by a review
+	Command string
+
+	// Actor the name of the actor involved in the reply
+	Actor string
+
+	// Requirement the description of the permission required by the command
+	Requirement string
+
+	// Jobs comma separated jobs involved in the reply
+	Jobs string
+
+	// Users comma separated logins involved in the reply
+	Users string
+
+	// Commands comma separated commands involved in the reply
+	Commands string
+}
+
+// Default returns the configuration used when the repository does not provide one
+func Default() *Config {
+	return &Config{
+		Actors: map[string]ActorConfig{},
+		Labels: Labels{
+			HelpWanted: actors.HelpWantedLabel,
+			LGTM:       actors.LGTMLabel,
+			Approved:   actors.ApprovedLabel,
+			Hold:       actors.HoldLabel,
+		},
+		Reactions: Reactions{
+			Commend: actors.CommendReaction,
+			Rocket:  actors.RocketReaction,
+		},
+		Replies: Replies{
+			AlreadyAssigned:     "@{{ .User }} The issue has been assigned to you. Please do not attempt to assign it",
+			NotAssigned:         "@{{ .User }} This issue is no assigned to you. Please do not try to unassign it again",
+			NotAssignable:       "@{{ .User }} These users '({{ .Users }})' cannot be assigned, only users with access to the repository can be assigned",
+			ChecksPassed:        "@{{ .User }} The current checks run has all been run successfully and there is no need to rerun it again",
+			LabelsNotConfigured: "@{{ .User }} These labels '({{ .Labels }})' cannot be used because they are not configured in the repo.",
+			LabelsNotExist:      "@{{ .User }} These labels '({{ .Labels }})' cannot be applied to issues because they are not exist in the issue.",
+			LabelsRestricted:    "@{{ .User }} These labels '({{ .Labels }})' are restricted, you are not allowed to add or remove them",
+			LabelsManaged:       "@{{ .User }} These labels '({{ .Labels }})' are maintained by their own commands, use '({{ .Commands }})' instead",
+			SelfLGTM:            "@{{ .User }} You cannot LGTM your own pull request",
+			LGTMRemoved:         "New changes are detected, the '{{ .Label }}' label has been removed",
+			PermissionDenied:    "@{{ .User }} You are not allowed to {{ if .Command }}use the '/{{ .Command }}' command{{ else }}trigger the '{{ .Actor }}' actor{{ end }}, it requires {{ .Requirement }}",
+			CommandsSummary:     "@{{ .User }} Results of the commands in your comment:",
+			JobsNotFound:        "@{{ .User }} These jobs '({{ .Jobs }})' cannot be found, use '/test ?' to list the available jobs",
+			JobsList:            "@{{ .User }} The jobs of the pull request:",
+			ActorsFailed:        "@{{ .User }} Your command could not be handled completely, the following actors failed:",
+		},
+		Permissions: map[string]Permission{
+			"assign":    {Role: CollaboratorRole},
+			"hold":      {Role: CollaboratorRole},
+			"lifecycle": {Role: CollaboratorRole},
+		},
+		Retest: Retest{
+			Conclusions: []string{"failure", "cancelled", "timed_out", "action_required", "startup_failure", "stale"},
+		},
+		Assign: Assign{
+			PullRequests: true,
+		},
+		Label: Label{
+			Shortcuts: []string{"kind", "priority", "area"},
+			Exclusive: []string{"priority"},
+		},
+		ReportFailures: true,
+	}
+}
+
+// Parse parses the configuration on top of the default configuration.
+// Unknown fields are rejected so that typos do not go unnoticed.
+func Parse(content []byte) (*Config, error) {
+	cfg := Default()
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Load reads the configuration file, the default configuration is returned if the file does not exist.
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return Default(), nil
+	case err != nil:
+		return nil, err
+	default:
+		return Parse(content)
+	}
+}
+
+// Validate checks the configuration, knownActors and knownCommands contain the names of all registered actors
+// and of the commands they handle
+func (c *Config) Validate(knownActors, knownCommands []string) error {
+	var errG *multierror.Error
+
+	known := sets.New(knownActors...)
+	for name := range c.Actors {
+		if !known.Has(name) {
+			errG = multierror.Append(errG, fmt.Errorf("actors: unknown actor '%s', available actors: [%s]", name, strings.Join(sets.List(known), ",")))
+		}
+	}
+
+	commands := sets.New(knownCommands...)
+	for name, permission := range c.Permissions {
+		if !known.Has(name) && !commands.Has(name) {
+			errG = multierror.Append(errG, fmt.Errorf("permissions: unknown actor or command '%s', available actors: [%s], available commands: [%s]",
+				name, strings.Join(sets.List(known), ","), strings.Join(sets.List(commands), ",")))
+		}
+		errG = multierror.Append(errG, validatePermission("permissions."+name, permission)...)
+	}
+
+	for label, permission := range c.Label.Restricted {
+		if permission.IsEmpty() {
+			errG = multierror.Append(errG, fmt.Errorf("label.restricted.%s: requirement must not be empty", label))
+		}
+		errG = multierror.Append(errG, validatePermission("label.restricted."+label, permission)...)
+	}
+	for field, namespaces := range map[string][]string{
+		"shortcuts": c.Label.Shortcuts,
+		"exclusive": c.Label.Exclusive,
+	} {
+		for _, namespace := range namespaces {
+			if len(strings.TrimSpace(namespace)) == 0 || strings.ContainsAny(namespace, "/ ") {
+				errG = multierror.Append(errG, fmt.Errorf("label.%s: invalid namespace '%s', expected a label prefix without '/'", field, namespace))
+			}
+		}
+	}
+
+	for field, label := range map[string]string{
+		"helpWanted": c.Labels.HelpWanted,
+		"lgtm":       c.Labels.LGTM,
+		"approved":   c.Labels.Approved,
+		"hold":       c.Labels.Hold,
+	} {
+		if len(strings.TrimSpace(label)) == 0 {
+			errG = multierror.Append(errG, fmt.Errorf("labels.%s: label name must not be empty", field))
+		}
+	}
+
+	for field, reaction := range map[string]string{
+		"commend": c.Reactions.Commend,
+		"rocket":  c.Reactions.Rocket,
+	} {
+		if !validReactions.Has(reaction) {
+			errG = multierror.Append(errG, fmt.Errorf("reactions.%s: invalid reaction '%s', available reactions: [%s]", field, reaction, strings.Join(sets.List(validReactions), ",")))
+		}
+	}
+
+	for field, reply := range map[string]string{
+		"alreadyAssigned":     c.Replies.AlreadyAssigned,
+		"notAssigned":         c.Replies.NotAssigned,
+		"notAssignable":       c.Replies.NotAssignable,
+		"checksPassed":        c.Replies.ChecksPassed,
+		"labelsNotConfigured": c.Replies.LabelsNotConfigured,
+		"labelsNotExist":      c.Replies.LabelsNotExist,
+		"labelsRestricted":    c.Replies.LabelsRestricted,
+		"labelsManaged":       c.Replies.LabelsManaged,
+		"selfLGTM":            c.Replies.SelfLGTM,
+		"lgtmRemoved":         c.Replies.LGTMRemoved,
+		"permissionDenied":    c.Replies.PermissionDenied,
+		"commandsSummary":     c.Replies.CommandsSummary,
+		"jobsNotFound":        c.Replies.JobsNotFound,
+		"jobsList":            c.Replies.JobsList,
+		"actorsFailed":        c.Replies.ActorsFailed,
+	} {
+		if len(strings.TrimSpace(reply)) == 0 {
+			errG = multierror.Append(errG, fmt.Errorf("replies.%s: reply must not be empty", field))
+			continue
+		}
+		if _, err := RenderReply(reply, ReplyData{}); err != nil {
+			errG = multierror.Append(errG, fmt.Errorf("replies.%s: %w", field, err))
+		}
+	}
+
+	if len(c.Retest.Conclusions) == 0 {
+		errG = multierror.Append(errG, errors.New("retest.conclusions: conclusions must not be empty"))
+	}
+	for _, conclusion := range c.Retest.Conclusions {
+		if !validConclusions.Has(conclusion) {
+			errG = multierror.Append(errG, fmt.Errorf("retest.conclusions: invalid conclusion '%s', available conclusions: [%s]", conclusion, strings.Join(sets.List(validConclusions), ",")))
+		}
+	}
+
+	if errG.ErrorOrNil() == nil {
+		return nil
+	}
+
+	// keep the error message stable regardless of the map iteration order
+	sort.Slice(errG.Errors, func(i, j int) bool {
+		return errG.Errors[i].Error() < errG.Errors[j].Error()
+	})
+	return errG
+}
+
+// ActorEnabled reports whether the actor is enabled
+func (c *Config) ActorEnabled(name string) bool {
+	actorConfig, ok := c.Actors[name]
+	if !ok || actorConfig.Enabled == nil {
+		return true
+	}
+
+	return *actorConfig.Enabled
+}
+
+// Permission returns the permission requirement of the command, it falls back to the requirement of the actor
+// when the command does not have its own. command is empty for events which are not comments.
+func (c *Config) Permission(actor, command string) Permission {
+	if permission, ok := c.Permissions[command]; ok && len(command) != 0 {
+		return permission
+	}
+
+	return c.Permissions[actor]
+}
+
+// RenderReply renders the reply template with the data
+func RenderReply(tmpl string, data ReplyData) (string, error) {
+	t, err := template.New("reply").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// validatePermission checks the roles and teams of the requirement, field is the path of the requirement in errors
+func validatePermission(field string, permission Permission) []error {
+	var errs []error
+	if len(permission.Role) != 0 && !slices.Contains(Roles, permission.Role) {
+		errs = append(errs, fmt.Errorf("%s.role: invalid role '%s', available roles: [%s]", field, permission.Role, strings.Join(Roles, ",")))
+	}
+	for _, team := range permission.Teams {
+		if parts := strings.Split(team, "/"); len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			errs = append(errs, fmt.Errorf("%s.teams: invalid team '%s', expected 'org/team-slug' format", field, team))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+)
+
+var (
+	knownActors   = []string{"approve", "assign", "cc", "hold", "label", "lgtm", "lifecycle", "retest"}
+	knownCommands = []string{"approve", "assign", "unassign", "cc", "uncc", "hold", "label", "unlabel", "kind", "lgtm", "close", "reopen", "retest", "test"}
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		caseName string
+		content  string
+		expect   func(t *testing.T, cfg *Config)
+		wantErr  bool
+	}{
+		{
+			caseName: "empty configuration falls back to default values",
+			content:  "",
+			expect: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, Default(), cfg)
+			},
+		},
+		{
+			caseName: "override part of the configuration",
+			content: `
+actors:
+  cc:
+    enabled: false
+labels:
+  helpWanted: "status/help-wanted"
+reactions:
+  rocket: hooray
+replies:
+  checksPassed: "@{{ .User }} all green"
+retest:
+  conclusions: [failure]
+  workflowRuns: true
+hold:
+  checkName: hold
+assign:
+  pullRequests: false
+permissions:
+  label:
+    role: write
+  test:
+    users: [alice]
+reportFailures: false
+`,
+			expect: func(t *testing.T, cfg *Config) {
+				assert.False(t, cfg.ActorEnabled("cc"))
+				assert.True(t, cfg.ActorEnabled("assign"))
+				assert.Equal(t, "status/help-wanted", cfg.Labels.HelpWanted)
+				assert.Equal(t, actors.LGTMLabel, cfg.Labels.LGTM)
+				assert.Equal(t, "hooray", cfg.Reactions.Rocket)
+				assert.Equal(t, actors.CommendReaction, cfg.Reactions.Commend)
+				assert.Equal(t, "@{{ .User }} all green", cfg.Replies.ChecksPassed)
+				assert.Equal(t, Default().Replies.AlreadyAssigned, cfg.Replies.AlreadyAssigned)
+				assert.Equal(t, Retest{Conclusions: []string{"failure"}, WorkflowRuns: true}, cfg.Retest)
+				assert.False(t, cfg.ReportFailures)
+				assert.True(t, Default().ReportFailures)
+				assert.Equal(t, "hold", cfg.Hold.CheckName)
+				assert.Empty(t, Default().Hold.CheckName)
+				assert.False(t, cfg.Assign.PullRequests)
+				assert.True(t, Default().Assign.PullRequests)
+				// the default requirements are kept unless they are overridden
+				assert.Equal(t, Permission{Role: "write"}, cfg.Permission("label", "label"))
+				assert.Equal(t, Permission{Role: "triage"}, cfg.Permission("hold", "hold"))
+				// a command falls back to the requirement of its actor unless it has its own
+				assert.Equal(t, Permission{Role: "write"}, cfg.Permission("label", "kind"))
+				assert.Equal(t, Permission{Users: []string{"alice"}}, cfg.Permission("retest", "test"))
+				assert.True(t, cfg.Permission("retest", "retest").IsEmpty())
+				assert.True(t, cfg.Permission("retest", "").IsEmpty())
+			},
+		},
+		{
+			caseName: "unknown fields are rejected",
+			content: `
+label:
+  helpWanted: foo
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			cfg, err := Parse([]byte(tc.content))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tc.expect(t, cfg)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "not-exist.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+
+	path := filepath.Join(t.TempDir(), "actbot.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("labels:\n  lgtm: approved-by-reviewer\n"), 0o600))
+	cfg, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "approved-by-reviewer", cfg.Labels.LGTM)
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		caseName string
+		modify   func(cfg *Config)
+		errMsgs  []string
+	}{
+		{
+			caseName: "default configuration is valid",
+			modify:   func(*Config) {},
+		},
+		{
+			caseName: "unknown actor",
+			modify: func(cfg *Config) {
+				cfg.Actors["foo"] = ActorConfig{}
+			},
+			errMsgs: []string{"actors: unknown actor 'foo'"},
+		},
+		{
+			caseName: "permissions keyed by command",
+			modify: func(cfg *Config) {
+				cfg.Permissions["test"] = Permission{Role: "write"}
+				cfg.Permissions["kind"] = Permission{Users: []string{"alice"}}
+			},
+		},
+		{
+			caseName: "empty label and invalid reaction",
+			modify: func(cfg *Config) {
+				cfg.Labels.LGTM = " "
+				cfg.Reactions.Commend = "thumbs-up"
+			},
+			errMsgs: []string{
+				"labels.lgtm: label name must not be empty",
+				"reactions.commend: invalid reaction 'thumbs-up'",
+			},
+		},
+		{
+			caseName: "invalid permissions",
+			modify: func(cfg *Config) {
+				cfg.Permissions["label"] = Permission{Role: "owner", Teams: []string{"reviewers"}}
+				cfg.Permissions["foo"] = Permission{Role: "write"}
+				cfg.Permissions["unlabel"] = Permission{Role: "write"}
+			},
+			errMsgs: []string{
+				"permissions: unknown actor or command 'foo'",
+				"permissions.label.role: invalid role 'owner'",
+				"permissions.label.teams: invalid team 'reviewers'",
+			},
+		},
+		{
+			caseName: "invalid label policies",
+			modify: func(cfg *Config) {
+				cfg.Label.Restricted = map[string]Permission{
+					"priority/*": {Role: "owner"},
+					"lgtm":       {},
+				}
+				cfg.Label.Shortcuts = []string{"kind", "kind/bug"}
+				cfg.Label.Exclusive = []string{""}
+			},
+			errMsgs: []string{
+				"label.restricted.priority/*.role: invalid role 'owner'",
+				"label.restricted.lgtm: requirement must not be empty",
+				"label.shortcuts: invalid namespace 'kind/bug'",
+				"label.exclusive: invalid namespace ''",
+			},
+		},
+		{
+			caseName: "invalid retest conclusions",
+			modify: func(cfg *Config) {
+				cfg.Retest.Conclusions = []string{"failure", "failed"}
+			},
+			errMsgs: []string{"retest.conclusions: invalid conclusion 'failed'"},
+		},
+		{
+			caseName: "empty retest conclusions",
+			modify: func(cfg *Config) {
+				cfg.Retest.Conclusions = nil
+			},
+			errMsgs: []string{"retest.conclusions: conclusions must not be empty"},
+		},
+		{
+			caseName: "broken reply template",
+			modify: func(cfg *Config) {
+				cfg.Replies.NotAssigned = "@{{ .User "
+				cfg.Replies.SelfLGTM = "@{{ .Login }}"
+			},
+			errMsgs: []string{"replies.notAssigned", "replies.selfLGTM"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			cfg := Default()
+			tc.modify(cfg)
+
+			err := cfg.Validate(knownActors, knownCommands)
+			if len(tc.errMsgs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, msg := range tc.errMsgs {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func TestLabelRestriction(t *testing.T) {
+	label := Label{Restricted: map[string]Permission{
+		"priority/*":  {Role: "write"},
+		"priority/p0": {Role: "maintain"},
+		"lgtm":        {Users: []string{"alice"}},
+	}}
+
+	assert.Equal(t, Permission{Role: "maintain"}, label.Restriction("priority/p0"))
+	assert.Equal(t, Permission{Role: "write"}, label.Restriction("priority/p1"))
+	assert.Equal(t, Permission{Users: []string{"alice"}}, label.Restriction("lgtm"))
+	assert.True(t, label.Restriction("kind/bug").IsEmpty())
+	assert.True(t, label.Restriction("help wanted").IsEmpty())
+}
+
+func TestRenderReply(t *testing.T) {
+	reply, err := RenderReply(Default().Replies.LabelsNotConfigured, ReplyData{User: "foo", Labels: "a,b"})
+	require.NoError(t, err)
+	assert.Equal(t, "@foo These labels '(a,b)' cannot be used because they are not configured in the repo.", reply)
+}
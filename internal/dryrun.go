@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// dryRunTransport lets read requests through and only logs the requests which would mutate the repository,
+// so that an event can be replayed against the real API without any side effect.
+type dryRunTransport struct {
+	base http.RoundTripper
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+	}
+	logger.Infof("dry-run: skip %s %s %s", req.Method, req.URL.Path, bytes.TrimSpace(body))
+
+	// go-github tolerates an empty body, so every mutation looks successful to the actors
+	return &http.Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunTransport(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		_, _ = fmt.Fprint(w, `{"number":1}`)
+	}))
+	defer server.Close()
+
+	ghClient := github.NewClient(&http.Client{Transport: &dryRunTransport{base: http.DefaultTransport}})
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	ghClient.BaseURL = baseURL
+
+	issue, _, err := ghClient.Issues.Get(context.Background(), "foo", "bar", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, issue.GetNumber())
+
+	// mutations look successful to the caller but never reach the server
+	_, _, err = ghClient.Issues.AddLabelsToIssue(context.Background(), "foo", "bar", 1, []string{"lgtm"})
+	require.NoError(t, err)
+	_, _, err = ghClient.Issues.CreateComment(context.Background(), "foo", "bar", 1, &github.IssueComment{Body: github.Ptr("hi")})
+	require.NoError(t, err)
+	_, err = ghClient.Issues.RemoveLabelForIssue(context.Background(), "foo", "bar", 1, "lgtm")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"GET /repos/foo/bar/issues/1"}, calls)
+}
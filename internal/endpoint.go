@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v72/github"
+)
+
+const (
+	defaultAPIURL    = "https://api.github.com"
+	defaultServerURL = "https://github.com"
+
+	enterpriseAPIPath = "/api/v3"
+)
+
+// Endpoint where the GitHub API is served, github.com is used when the API URL is empty
+type Endpoint struct {
+	// APIURL the REST API URL of GitHub Enterprise Server, e.g. https://github.example.com/api/v3
+	APIURL string
+
+	// UploadURL the upload URL of GitHub Enterprise Server, it is derived from the API URL when it is empty
+	UploadURL string
+}
+
+// endpointFromEnv detects GitHub Enterprise Server from the default environment variables of GitHub Actions
+func endpointFromEnv() Endpoint {
+	var (
+		apiURL    = strings.TrimSuffix(os.Getenv("GITHUB_API_URL"), "/")
+		serverURL = strings.TrimSuffix(os.Getenv("GITHUB_SERVER_URL"), "/")
+	)
+
+	switch {
+	case len(apiURL) != 0 && apiURL != defaultAPIURL:
+		return Endpoint{APIURL: apiURL, UploadURL: serverURL}
+	case len(serverURL) != 0 && serverURL != defaultServerURL:
+		return Endpoint{APIURL: serverURL}
+	default:
+		return Endpoint{}
+	}
+}
+
+// apply points the client to GitHub Enterprise Server, the client is returned as is for github.com
+func (e Endpoint) apply(ghClient *github.Client) (*github.Client, error) {
+	if len(e.APIURL) == 0 {
+		return ghClient, nil
+	}
+
+	uploadURL := e.UploadURL
+	if len(uploadURL) == 0 {
+		// the upload API is served under /api/uploads of the same host
+		uploadURL = strings.TrimSuffix(strings.TrimSuffix(e.APIURL, "/"), enterpriseAPIPath)
+	}
+
+	return ghClient.WithEnterpriseURLs(e.APIURL, uploadURL)
+}
+
+// baseURL returns the REST API base URL, it is empty for github.com
+func (e Endpoint) baseURL() (string, error) {
+	if len(e.APIURL) == 0 {
+		return "", nil
+	}
+
+	ghClient, err := e.apply(github.NewClient(nil))
+	if err != nil {
+		return "", err
+	}
+
+	return ghClient.BaseURL.String(), nil
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointFromEnv(t *testing.T) {
+	cases := []struct {
+		caseName  string
+		apiURL    string
+		serverURL string
+		expect    Endpoint
+	}{
+		{
+			caseName: "outside GitHub Actions",
+		},
+		{
+			caseName:  "github.com",
+			apiURL:    "https://api.github.com",
+			serverURL: "https://github.com",
+		},
+		{
+			caseName:  "GitHub Enterprise Server",
+			apiURL:    "https://github.example.com/api/v3",
+			serverURL: "https://github.example.com",
+			expect:    Endpoint{APIURL: "https://github.example.com/api/v3", UploadURL: "https://github.example.com"},
+		},
+		{
+			caseName:  "GitHub Enterprise Server without API URL",
+			serverURL: "https://github.example.com/",
+			expect:    Endpoint{APIURL: "https://github.example.com"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			t.Setenv("GITHUB_API_URL", tc.apiURL)
+			t.Setenv("GITHUB_SERVER_URL", tc.serverURL)
+			assert.Equal(t, tc.expect, endpointFromEnv())
+		})
+	}
+}
+
+func TestEnterpriseClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/repos/foo/bar/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(github.Issue{Title: github.Ptr(r.Header.Get("Authorization"))}))
+	})
+	mux.HandleFunc("GET /api/v3/repos/foo/bar/installation", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"id":7}`)
+	})
+	mux.HandleFunc("POST /api/v3/app/installations/7/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"token":"ghs_app","expires_at":"2999-01-01T00:00:00Z"}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cases := []struct {
+		caseName string
+		creds    Credentials
+		endpoint Endpoint
+		dryRun   bool
+		expect   string
+	}{
+		{
+			caseName: "token",
+			creds:    Credentials{Token: "foo"},
+			endpoint: Endpoint{APIURL: server.URL},
+			expect:   "Bearer foo",
+		},
+		{
+			caseName: "token in dry-run mode",
+			creds:    Credentials{Token: "foo"},
+			endpoint: Endpoint{APIURL: server.URL + "/api/v3/", UploadURL: server.URL},
+			dryRun:   true,
+			expect:   "Bearer foo",
+		},
+		{
+			caseName: "GitHub App",
+			creds:    Credentials{AppID: 42, AppPrivateKey: keyPEM},
+			endpoint: Endpoint{APIURL: server.URL + "/api/v3"},
+			expect:   "token ghs_app",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			ghClient, err := newGitHubClient(tc.creds, tc.endpoint, "foo/bar", tc.dryRun, defaultCallTimeout)
+			require.NoError(t, err)
+			assert.Equal(t, server.URL+"/api/v3/", ghClient.BaseURL.String())
+			assert.Equal(t, server.URL+"/api/uploads/", ghClient.UploadURL.String())
+
+			issue, _, err := ghClient.Issues.Get(context.Background(), "foo", "bar", 1)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, issue.GetTitle())
+		})
+	}
+}
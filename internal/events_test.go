@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShyunnY/actbot/internal/config"
+	"github.com/ShyunnY/actbot/internal/githubtest"
+)
+
+var update = flag.Bool("update", false, "regenerate the golden files of the event fixtures")
+
+// eventsDir holds the event fixtures as <event>/<name>.json, the API mutations caused by
+// each fixture are recorded in <event>/<name>.golden next to it
+const eventsDir = "testdata/events"
+
+// fixtureHeadSHA the head of the pull requests of the fixtures
+const fixtureHeadSHA = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+
+// fixtureSetups seeds the state a fixture depends on beyond its payload, keyed by <event>/<name>
+var fixtureSetups = map[string]func(repo *githubtest.Repo, cfg *config.Config){
+	"issue_comment/label": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.Labels = append(repo.Labels, "kind/bug")
+	},
+	"issue_comment/label-pr": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.Labels = append(repo.Labels, "kind/bug", "help wanted")
+	},
+	"issue_comment/label-lgtm-self": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.Labels = append(repo.Labels, "lgtm")
+	},
+	"issue_comment/priority": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.Labels = append(repo.Labels, "kind/bug", "priority/p0")
+	},
+	"issue_comment/priority-twice": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.Labels = append(repo.Labels, "priority/p0", "priority/p1")
+	},
+	"issue_comment/retest": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.CheckRuns[fixtureHeadSHA] = []*github.CheckRun{
+			{ID: github.Ptr[int64](301), Name: github.Ptr("build"), Status: github.Ptr("completed"), Conclusion: github.Ptr("success")},
+			{ID: github.Ptr[int64](302), Name: github.Ptr("e2e"), Status: github.Ptr("completed"), Conclusion: github.Ptr("failure")},
+		}
+	},
+	"issue_comment/assign-others": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.Permissions["bob"] = "triage"
+		repo.Permissions["carol"] = "write"
+	},
+	"issue_comment/hold": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.Permissions["bob"] = "triage"
+		cfg.Hold.CheckName = "hold"
+	},
+	"issue_comment/close-denied": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.Labels = append(repo.Labels, "kind/bug")
+	},
+	"issue_comment/denied": func(repo *githubtest.Repo, cfg *config.Config) {
+		cfg.Permissions["cc"] = config.Permission{Users: []string{"alice"}}
+	},
+	"pull_request/opened": func(repo *githubtest.Repo, cfg *config.Config) {
+		repo.Issues[34].PullRequest.Files = []string{"docs/install.md"}
+		repo.AddFile(githubtest.DefaultBranch, "docs/OWNERS", "approvers:\n  - carol\n")
+	},
+}
+
+// TestEventFixtures replays each event fixture through dispatch against the fake API,
+// and compares the mutations sent to the API with the golden file of the fixture.
+// Run the test with -update to regenerate the golden files.
+func TestEventFixtures(t *testing.T) {
+	payloads, err := filepath.Glob(filepath.Join(eventsDir, "*", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, payloads)
+
+	for _, path := range payloads {
+		var (
+			event  = filepath.Base(filepath.Dir(path))
+			name   = event + "/" + strings.TrimSuffix(filepath.Base(path), ".json")
+			golden = strings.TrimSuffix(path, ".json") + ".golden"
+		)
+		t.Run(name, func(t *testing.T) {
+			server := githubtest.NewServer(t)
+			cfg := config.Default()
+			repo := seedFixture(t, server, path)
+			if setup, ok := fixtureSetups[name]; ok {
+				setup(repo, cfg)
+			}
+
+			require.NoError(t, dispatch(context.Background(), event, path, server.Client(), cfg))
+
+			var got strings.Builder
+			for _, req := range server.MutationRequests() {
+				got.WriteString(req.String())
+				if len(req.Body) != 0 {
+					got.WriteString(" " + req.Body)
+				}
+				got.WriteString("\n")
+			}
+
+			if *update {
+				require.NoError(t, os.WriteFile(golden, []byte(got.String()), 0o644))
+				return
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err, "run the test with -update to create the golden file")
+			assert.Equal(t, string(want), got.String())
+		})
+	}
+}
+
+// seedFixture creates the repository, and the issue or pull request of the payload in the fake API
+func seedFixture(t *testing.T, server *githubtest.Server, path string) *githubtest.Repo {
+	payload, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var event struct {
+		Repository  *github.Repository  `json:"repository"`
+		Issue       *github.Issue       `json:"issue"`
+		PullRequest *github.PullRequest `json:"pull_request"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &event))
+
+	repo := server.Repo(event.Repository.GetFullName())
+	var (
+		issue  *githubtest.Issue
+		labels []*github.Label
+	)
+	switch {
+	case event.PullRequest != nil:
+		pr := event.PullRequest
+		issue = repo.AddPullRequest(pr.GetNumber(), pr.GetUser().GetLogin(), pr.GetHead().GetSHA())
+		labels = pr.Labels
+	case event.Issue != nil && event.Issue.IsPullRequest():
+		// the head of a pull request is not part of comment payloads
+		issue = repo.AddPullRequest(event.Issue.GetNumber(), event.Issue.GetUser().GetLogin(), fixtureHeadSHA)
+		labels = event.Issue.Labels
+	case event.Issue != nil:
+		issue = repo.AddIssue(event.Issue.GetNumber(), event.Issue.GetUser().GetLogin())
+		labels = event.Issue.Labels
+	default:
+		return repo
+	}
+
+	for _, label := range labels {
+		issue.Labels = append(issue.Labels, label.GetName())
+		repo.Labels = append(repo.Labels, label.GetName())
+	}
+	if event.Issue != nil {
+		for _, assignee := range event.Issue.Assignees {
+			issue.Assignees = append(issue.Assignees, assignee.GetLogin())
+		}
+	}
+
+	return repo
+}
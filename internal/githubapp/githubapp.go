@@ -0,0 +1,173 @@
+package githubapp
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v72/github"
+)
+
+// refreshMargin installation tokens are refreshed ahead of their expiry,
+// so that a token never expires in the middle of a run
+const refreshMargin = 5 * time.Minute
+
+// Config the credentials of a GitHub App
+type Config struct {
+	// AppID the id of the GitHub App
+	AppID int64
+
+	// PrivateKey the PEM encoded private key of the GitHub App
+	PrivateKey []byte
+
+	// InstallationID the installation to act as, it is discovered when it is zero
+	InstallationID int64
+
+	// Repo the full name of the repository used to discover the installation,
+	// the only installation of the app is used when it is empty
+	Repo string
+
+	// BaseURL the API base URL, defaults to the base URL of github.com
+	BaseURL string
+}
+
+// Transport authenticates requests as an installation of a GitHub App,
+// installation tokens are minted on demand, cached and refreshed shortly before they expire.
+type Transport struct {
+	base      http.RoundTripper
+	appClient *github.Client
+	repo      string
+
+	mu             sync.Mutex
+	installationID int64
+	token          string
+	expiresAt      time.Time
+
+	now func() time.Time
+}
+
+// NewTransport returns a transport authenticating requests as an installation of the app, base defaults to
+// http.DefaultTransport. No request is sent until the first request goes through the transport.
+func NewTransport(base http.RoundTripper, cfg Config) (*Transport, error) {
+	if cfg.AppID <= 0 {
+		return nil, fmt.Errorf("invalid app id %d", cfg.AppID)
+	}
+	key, err := ParsePrivateKey(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key of app %d: %w", cfg.AppID, err)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	t := &Transport{
+		base:           base,
+		repo:           cfg.Repo,
+		installationID: cfg.InstallationID,
+		now:            time.Now,
+	}
+	t.appClient = github.NewClient(&http.Client{
+		Transport: &appTransport{base: base, appID: cfg.AppID, key: key},
+	})
+	if len(cfg.BaseURL) != 0 {
+		baseURL, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("invalid base url '%s': %w", cfg.BaseURL, err)
+		}
+		t.appClient.BaseURL = baseURL
+	}
+
+	return t, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// a RoundTripper must not modify the request
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Authorization", "token "+token)
+
+	return t.base.RoundTrip(authReq)
+}
+
+// Token returns a valid installation token, a new token is minted when the cached one is about to expire
+func (t *Transport) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.token) != 0 && t.now().Before(t.expiresAt.Add(-refreshMargin)) {
+		return t.token, nil
+	}
+
+	if t.installationID == 0 {
+		id, err := t.discoverInstallation(ctx)
+		if err != nil {
+			return "", err
+		}
+		t.installationID = id
+	}
+
+	token, _, err := t.appClient.Apps.CreateInstallationToken(ctx, t.installationID, nil)
+	if err != nil {
+		return "", fmt.Errorf("create token of installation %d: %w", t.installationID, err)
+	}
+	t.token, t.expiresAt = token.GetToken(), token.GetExpiresAt().Time
+
+	return t.token, nil
+}
+
+// discoverInstallation finds the installation on the repository,
+// or the only installation of the app when no repository is provided
+func (t *Transport) discoverInstallation(ctx context.Context) (int64, error) {
+	if len(t.repo) != 0 {
+		owner, repo, ok := strings.Cut(t.repo, "/")
+		if !ok {
+			return 0, fmt.Errorf("invalid repository '%s'", t.repo)
+		}
+		installation, _, err := t.appClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+		if err != nil {
+			return 0, fmt.Errorf("find installation of repository '%s': %w", t.repo, err)
+		}
+		return installation.GetID(), nil
+	}
+
+	installations, _, err := t.appClient.Apps.ListInstallations(ctx, &github.ListOptions{PerPage: 2})
+	switch {
+	case err != nil:
+		return 0, fmt.Errorf("list installations: %w", err)
+	case len(installations) == 0:
+		return 0, errors.New("the app has not been installed")
+	case len(installations) > 1:
+		return 0, errors.New("the app has several installations, the installation id must be provided")
+	default:
+		return installations[0].GetID(), nil
+	}
+}
+
+// appTransport authenticates requests as the app itself with a JWT
+type appTransport struct {
+	base  http.RoundTripper
+	appID int64
+	key   *rsa.PrivateKey
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := signJWT(t.appID, t.key, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("sign jwt of app %d: %w", t.appID, err)
+	}
+
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Authorization", "Bearer "+jwt)
+
+	return t.base.RoundTrip(authReq)
+}
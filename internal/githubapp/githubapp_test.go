@@ -0,0 +1,192 @@
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// verifyJWT verifies the signature of the JWT and returns its claims
+func verifyJWT(t *testing.T, key *rsa.PrivateKey, jwt string) map[string]any {
+	t.Helper()
+
+	parts := strings.Split(jwt, ".")
+	require.Len(t, parts, 3)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+	claimBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims map[string]any
+	require.NoError(t, json.Unmarshal(claimBytes, &claims))
+
+	return claims
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, pkcs1 := newKey(t)
+
+	parsed, err := ParsePrivateKey(pkcs1)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(parsed))
+
+	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	parsed, err = ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Bytes}))
+	require.NoError(t, err)
+	assert.True(t, key.Equal(parsed))
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestSignJWT(t *testing.T) {
+	key, _ := newKey(t)
+	now := time.Unix(1700000000, 0)
+
+	jwt, err := signJWT(42, key, now)
+	require.NoError(t, err)
+
+	claims := verifyJWT(t, key, jwt)
+	assert.Equal(t, "42", claims["iss"])
+	assert.EqualValues(t, now.Add(-time.Minute).Unix(), claims["iat"])
+	assert.EqualValues(t, now.Add(9*time.Minute).Unix(), claims["exp"])
+}
+
+func TestTransport(t *testing.T) {
+	key, keyPEM := newKey(t)
+
+	var (
+		minted     int
+		discovered int
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/foo/bar/installation", func(w http.ResponseWriter, r *http.Request) {
+		discovered++
+		verifyJWT(t, key, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		_, _ = fmt.Fprint(w, `{"id":7}`)
+	})
+	mux.HandleFunc("POST /app/installations/7/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		minted++
+		verifyJWT(t, key, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		_, _ = fmt.Fprintf(w, `{"token":"ghs_%d","expires_at":"2030-01-01T01:00:00Z"}`, minted)
+	})
+	mux.HandleFunc("GET /repos/foo/bar/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Header.Get("Authorization"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	transport, err := NewTransport(nil, Config{AppID: 42, PrivateKey: keyPEM, Repo: "foo/bar", BaseURL: server.URL})
+	require.NoError(t, err)
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	transport.now = func() time.Time { return now }
+
+	authorization := func() string {
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/repos/foo/bar/issues/1")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	assert.Equal(t, "token ghs_1", authorization())
+	// the token is cached while it is valid
+	assert.Equal(t, "token ghs_1", authorization())
+	assert.Equal(t, 1, minted)
+
+	// the token is refreshed shortly before it expires
+	now = now.Add(56 * time.Minute)
+	assert.Equal(t, "token ghs_2", authorization())
+	assert.Equal(t, 2, minted)
+	// the installation is only discovered once
+	assert.Equal(t, 1, discovered)
+}
+
+func TestDiscoverOnlyInstallation(t *testing.T) {
+	_, keyPEM := newKey(t)
+
+	cases := []struct {
+		caseName      string
+		installations string
+		expect        int64
+		errMsg        string
+	}{
+		{
+			caseName:      "the only installation is used",
+			installations: `[{"id":7}]`,
+			expect:        7,
+		},
+		{
+			caseName:      "app without installation",
+			installations: `[]`,
+			errMsg:        "the app has not been installed",
+		},
+		{
+			caseName:      "app with several installations",
+			installations: `[{"id":7},{"id":8}]`,
+			errMsg:        "the installation id must be provided",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/app/installations", r.URL.Path)
+				_, _ = fmt.Fprint(w, tc.installations)
+			}))
+			defer server.Close()
+
+			transport, err := NewTransport(nil, Config{AppID: 42, PrivateKey: keyPEM, BaseURL: server.URL})
+			require.NoError(t, err)
+
+			id, err := transport.discoverInstallation(context.Background())
+			if len(tc.errMsg) != 0 {
+				assert.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, id)
+		})
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	_, keyPEM := newKey(t)
+
+	_, err := NewTransport(nil, Config{PrivateKey: keyPEM})
+	assert.ErrorContains(t, err, "invalid app id 0")
+
+	_, err = NewTransport(nil, Config{AppID: 42, PrivateKey: []byte("foo")})
+	assert.ErrorContains(t, err, "parse private key of app 42")
+}
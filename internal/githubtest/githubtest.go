@@ -0,0 +1,880 @@
+// Package githubtest provides an in-memory fake of the GitHub REST API, so that the whole flow of actors can be
+// exercised offline. The fake keeps the state of repositories, which is seeded and inspected by tests directly.
+package githubtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+)
+
+// DefaultBranch the default branch of every repository
+const DefaultBranch = "main"
+
+// BotLogin the login the client is authenticated as, comments created through the fake are written by it
+const BotLogin = "actbot"
+
+// Server the fake GitHub API, requests which are not supported fail the test.
+// The state must only be inspected once the requests under test have completed.
+type Server struct {
+	*httptest.Server
+
+	t  testing.TB
+	mu sync.Mutex
+
+	repos map[string]*Repo
+
+	// Teams the logins of the active members of each team, keyed by "org/team-slug"
+	Teams map[string][]string
+
+	requests []Request
+	failures map[string]int
+	nextID   int64
+}
+
+// Repo the state of a repository
+type Repo struct {
+	// Labels the labels defined in the repository
+	Labels []string
+
+	// Issues the issues and pull requests keyed by number
+	Issues map[int]*Issue
+
+	// Permissions the roles of the collaborators keyed by login, e.g. "write", "triage"
+	Permissions map[string]string
+
+	// Reactions the reactions added to issue comments, keyed by comment id
+	Reactions map[int64][]string
+
+	// ReviewCommentReactions the reactions added to pull request review comments, keyed by comment id
+	ReviewCommentReactions map[int64][]string
+
+	// CheckRuns the check runs keyed by head sha
+	CheckRuns map[string][]*github.CheckRun
+
+	// Jobs the workflow run of each job, keyed by job id
+	Jobs map[int64]int64
+
+	// RerunJobs the ids of the jobs which have been rerun
+	RerunJobs []int64
+
+	// RerunWorkflowRuns the ids of the workflow runs whose failed jobs have been rerun
+	RerunWorkflowRuns []int64
+
+	// Files the content of the files keyed by ref and then by path
+	Files map[string]map[string]string
+}
+
+// Issue an issue, or a pull request when PullRequest is set
+type Issue struct {
+	Number      int
+	User        string
+	State       string
+	StateReason string
+	Labels      []string
+	Assignees   []string
+	Comments    []*Comment
+	PullRequest *PullRequest
+}
+
+// PullRequest the pull request specific state of an issue
+type PullRequest struct {
+	HeadSHA            string
+	BaseRef            string
+	Files              []string
+	RequestedReviewers []string
+}
+
+// Request a request received by the fake
+type Request struct {
+	Method string
+	Path   string
+
+	// Body the request body with surrounding whitespace trimmed, it is empty when the request has no body
+	Body string
+}
+
+// String returns the request as "METHOD /path"
+func (r Request) String() string {
+	return r.Method + " " + r.Path
+}
+
+// Comment an issue comment
+type Comment struct {
+	ID   int64
+	User string
+	Body string
+}
+
+// NewServer starts a fake GitHub API which is closed at the end of the test
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		t:        t,
+		repos:    make(map[string]*Repo),
+		Teams:    make(map[string][]string),
+		failures: make(map[string]int),
+		nextID:   1000,
+	}
+
+	mux := http.NewServeMux()
+	for pattern, handler := range map[string]func(w http.ResponseWriter, r *http.Request, repo *Repo){
+		"GET /repos/{owner}/{repo}/labels":                                s.listLabels,
+		"GET /repos/{owner}/{repo}/issues/{number}":                       s.getIssue,
+		"PATCH /repos/{owner}/{repo}/issues/{number}":                     s.editIssue,
+		"POST /repos/{owner}/{repo}/issues/{number}/labels":               s.addLabels,
+		"DELETE /repos/{owner}/{repo}/issues/{number}/labels/{name...}":   s.removeLabel,
+		"POST /repos/{owner}/{repo}/issues/{number}/assignees":            s.addAssignees,
+		"DELETE /repos/{owner}/{repo}/issues/{number}/assignees":          s.removeAssignees,
+		"GET /repos/{owner}/{repo}/assignees/{login}":                     s.checkAssignee,
+		"GET /repos/{owner}/{repo}/issues/{number}/comments":              s.listComments,
+		"POST /repos/{owner}/{repo}/issues/{number}/comments":             s.createComment,
+		"PATCH /repos/{owner}/{repo}/issues/comments/{id}":                s.editComment,
+		"POST /repos/{owner}/{repo}/issues/comments/{id}/reactions":       s.addReaction,
+		"POST /repos/{owner}/{repo}/pulls/comments/{id}/reactions":        s.addReviewCommentReaction,
+		"GET /repos/{owner}/{repo}/pulls/{number}":                        s.getPullRequest,
+		"GET /repos/{owner}/{repo}/pulls/{number}/files":                  s.listFiles,
+		"POST /repos/{owner}/{repo}/pulls/{number}/requested_reviewers":   s.requestReviewers,
+		"DELETE /repos/{owner}/{repo}/pulls/{number}/requested_reviewers": s.removeReviewers,
+		"GET /repos/{owner}/{repo}/commits/{ref}/check-runs":              s.listCheckRuns,
+		"POST /repos/{owner}/{repo}/check-runs":                           s.createCheckRun,
+		"PATCH /repos/{owner}/{repo}/check-runs/{id}":                     s.updateCheckRun,
+		"GET /repos/{owner}/{repo}/actions/jobs/{id}":                     s.getJob,
+		"POST /repos/{owner}/{repo}/actions/jobs/{id}/rerun":              s.rerunJob,
+		"POST /repos/{owner}/{repo}/actions/runs/{id}/rerun-failed-jobs":  s.rerunWorkflowRun,
+		"GET /repos/{owner}/{repo}/contents/{path...}":                    s.getContents,
+		"GET /repos/{owner}/{repo}/collaborators/{login}/permission":      s.getPermission,
+	} {
+		mux.HandleFunc(pattern, s.repoHandler(handler))
+	}
+	mux.HandleFunc("GET /orgs/{org}/teams/{slug}/memberships/{login}", s.locked(s.getMembership))
+	mux.HandleFunc("GET /user", s.locked(s.getAuthenticatedUser))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("githubtest: unexpected request %s %s", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Client returns a client of the fake API
+func (s *Server) Client() *github.Client {
+	ghClient := github.NewClient(nil)
+	baseURL, err := url.Parse(s.URL + "/")
+	if err != nil {
+		s.t.Fatalf("githubtest: %v", err)
+	}
+	ghClient.BaseURL = baseURL
+
+	return ghClient
+}
+
+// Repo returns the state of the repository, it is created empty when it does not exist yet
+func (s *Server) Repo(fullName string) *Repo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, ok := s.repos[fullName]
+	if !ok {
+		repo = &Repo{
+			Issues:                 make(map[int]*Issue),
+			Permissions:            make(map[string]string),
+			Reactions:              make(map[int64][]string),
+			ReviewCommentReactions: make(map[int64][]string),
+			CheckRuns:              make(map[string][]*github.CheckRun),
+			Jobs:                   make(map[int64]int64),
+			Files:                  make(map[string]map[string]string),
+		}
+		s.repos[fullName] = repo
+	}
+
+	return repo
+}
+
+// Requests returns the requests received so far as "METHOD /path" in order
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make([]string, 0, len(s.requests))
+	for _, req := range s.requests {
+		requests = append(requests, req.String())
+	}
+
+	return requests
+}
+
+// Mutations returns the requests received so far which are not GET requests as "METHOD /path" in order
+func (s *Server) Mutations() []string {
+	var mutations []string
+	for _, req := range s.MutationRequests() {
+		mutations = append(mutations, req.String())
+	}
+
+	return mutations
+}
+
+// MutationRequests returns the requests received so far which are not GET requests, their bodies included
+func (s *Server) MutationRequests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var mutations []Request
+	for _, req := range s.requests {
+		if req.Method != http.MethodGet {
+			mutations = append(mutations, req)
+		}
+	}
+
+	return mutations
+}
+
+// Fail answers the requests matching "METHOD /path" with the status code instead of handling them
+func (s *Server) Fail(request string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[request] = status
+}
+
+// AddIssue adds an open issue written by the user
+func (r *Repo) AddIssue(number int, user string) *Issue {
+	issue := &Issue{Number: number, User: user, State: "open"}
+	r.Issues[number] = issue
+
+	return issue
+}
+
+// AddPullRequest adds an open pull request written by the user against the default branch
+func (r *Repo) AddPullRequest(number int, user, headSHA string) *Issue {
+	issue := r.AddIssue(number, user)
+	issue.PullRequest = &PullRequest{HeadSHA: headSHA, BaseRef: DefaultBranch}
+
+	return issue
+}
+
+// AddFile adds a file to the ref, an empty ref stands for the default branch
+func (r *Repo) AddFile(ref, path, content string) {
+	if len(ref) == 0 {
+		ref = DefaultBranch
+	}
+	if r.Files[ref] == nil {
+		r.Files[ref] = make(map[string]string)
+	}
+	r.Files[ref][path] = content
+}
+
+// CommentBodies returns the bodies of the comments of the issue in order
+func (i *Issue) CommentBodies() []string {
+	var bodies []string
+	for _, c := range i.Comments {
+		bodies = append(bodies, c.Body)
+	}
+
+	return bodies
+}
+
+// locked records the request, answers the configured failure if any, and serializes the handlers
+func (s *Server) locked(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		request := Request{Method: r.Method, Path: r.URL.Path, Body: string(bytes.TrimSpace(body))}
+		s.requests = append(s.requests, request)
+		if status, ok := s.failures[request.String()]; ok {
+			writeError(w, status, "injected failure")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// repoHandler resolves the repository of the request, unknown repositories are answered with 404
+func (s *Server) repoHandler(handler func(w http.ResponseWriter, r *http.Request, repo *Repo)) http.HandlerFunc {
+	return s.locked(func(w http.ResponseWriter, r *http.Request) {
+		repo, ok := s.repos[r.PathValue("owner")+"/"+r.PathValue("repo")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		handler(w, r, repo)
+	})
+}
+
+func (s *Server) listLabels(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	writeJSON(w, http.StatusOK, paginate(w, r, toLabels(repo.Labels)))
+}
+
+func (s *Server) getIssue(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	if issue, ok := s.issue(w, r, repo); ok {
+		writeJSON(w, http.StatusOK, issue.toGitHub())
+	}
+}
+
+func (s *Server) editIssue(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.issue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var req github.IssueRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if req.State != nil {
+		issue.State = req.GetState()
+		issue.StateReason = req.GetStateReason()
+	}
+	if req.Labels != nil {
+		issue.Labels = slices.Clone(*req.Labels)
+	}
+	if req.Assignees != nil {
+		issue.Assignees = slices.Clone(*req.Assignees)
+	}
+
+	writeJSON(w, http.StatusOK, issue.toGitHub())
+}
+
+func (s *Server) addLabels(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.issue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var labels []string
+	if !s.decode(w, r, &labels) {
+		return
+	}
+	for _, label := range labels {
+		// like GitHub, labels which are not defined yet are created on the fly
+		if !slices.Contains(repo.Labels, label) {
+			repo.Labels = append(repo.Labels, label)
+		}
+		if !slices.Contains(issue.Labels, label) {
+			issue.Labels = append(issue.Labels, label)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, toLabels(issue.Labels))
+}
+
+func (s *Server) removeLabel(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.issue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	index := slices.Index(issue.Labels, r.PathValue("name"))
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "Label does not exist")
+		return
+	}
+	issue.Labels = slices.Delete(issue.Labels, index, index+1)
+
+	writeJSON(w, http.StatusOK, toLabels(issue.Labels))
+}
+
+// addAssignees assigns every user, unlike GitHub which silently ignores the users who cannot be assigned
+func (s *Server) addAssignees(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.issue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Assignees []string `json:"assignees"`
+	}
+	if !s.decode(w, r, &req) {
+		return
+	}
+	for _, assignee := range req.Assignees {
+		if !slices.Contains(issue.Assignees, assignee) {
+			issue.Assignees = append(issue.Assignees, assignee)
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, issue.toGitHub())
+}
+
+func (s *Server) removeAssignees(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.issue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Assignees []string `json:"assignees"`
+	}
+	if !s.decode(w, r, &req) {
+		return
+	}
+	issue.Assignees = slices.DeleteFunc(issue.Assignees, func(assignee string) bool {
+		return slices.Contains(req.Assignees, assignee)
+	})
+
+	writeJSON(w, http.StatusOK, issue.toGitHub())
+}
+
+// checkAssignee answers 204 for the collaborators, which are the users who can be assigned
+func (s *Server) checkAssignee(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	if role, ok := repo.Permissions[r.PathValue("login")]; !ok || role == "none" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listComments(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.issue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	comments := make([]*github.IssueComment, 0, len(issue.Comments))
+	for _, c := range issue.Comments {
+		comments = append(comments, c.toGitHub())
+	}
+	writeJSON(w, http.StatusOK, paginate(w, r, comments))
+}
+
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.issue(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var req github.IssueComment
+	if !s.decode(w, r, &req) {
+		return
+	}
+	s.nextID++
+	comment := &Comment{ID: s.nextID, User: BotLogin, Body: req.GetBody()}
+	issue.Comments = append(issue.Comments, comment)
+
+	writeJSON(w, http.StatusCreated, comment.toGitHub())
+}
+
+func (s *Server) editComment(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	id, ok := s.id(w, r)
+	if !ok {
+		return
+	}
+
+	var req github.IssueComment
+	if !s.decode(w, r, &req) {
+		return
+	}
+	for _, issue := range repo.Issues {
+		for _, c := range issue.Comments {
+			if c.ID == id {
+				c.Body = req.GetBody()
+				writeJSON(w, http.StatusOK, c.toGitHub())
+				return
+			}
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) addReaction(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	s.react(w, r, repo.Reactions)
+}
+
+func (s *Server) addReviewCommentReaction(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	s.react(w, r, repo.ReviewCommentReactions)
+}
+
+// react records the reaction of the comment, the comment itself is not required to be known
+func (s *Server) react(w http.ResponseWriter, r *http.Request, reactions map[int64][]string) {
+	id, ok := s.id(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if !s.decode(w, r, &req) {
+		return
+	}
+	reactions[id] = append(reactions[id], req.Content)
+
+	writeJSON(w, http.StatusCreated, github.Reaction{Content: github.Ptr(req.Content)})
+}
+
+func (s *Server) getPullRequest(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	if issue, ok := s.pullRequest(w, r, repo); ok {
+		writeJSON(w, http.StatusOK, issue.toPullRequest())
+	}
+}
+
+func (s *Server) listFiles(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.pullRequest(w, r, repo)
+	if !ok {
+		return
+	}
+
+	files := make([]*github.CommitFile, 0, len(issue.PullRequest.Files))
+	for _, file := range issue.PullRequest.Files {
+		files = append(files, &github.CommitFile{Filename: github.Ptr(file)})
+	}
+	writeJSON(w, http.StatusOK, paginate(w, r, files))
+}
+
+func (s *Server) requestReviewers(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.pullRequest(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var req github.ReviewersRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	for _, reviewer := range req.Reviewers {
+		if reviewer == issue.User {
+			writeError(w, http.StatusUnprocessableEntity, "Review cannot be requested from pull request author.")
+			return
+		}
+	}
+	for _, reviewer := range req.Reviewers {
+		if !slices.Contains(issue.PullRequest.RequestedReviewers, reviewer) {
+			issue.PullRequest.RequestedReviewers = append(issue.PullRequest.RequestedReviewers, reviewer)
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, issue.toPullRequest())
+}
+
+func (s *Server) removeReviewers(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	issue, ok := s.pullRequest(w, r, repo)
+	if !ok {
+		return
+	}
+
+	var req github.ReviewersRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	issue.PullRequest.RequestedReviewers = slices.DeleteFunc(issue.PullRequest.RequestedReviewers, func(reviewer string) bool {
+		return slices.Contains(req.Reviewers, reviewer)
+	})
+
+	writeJSON(w, http.StatusOK, issue.toPullRequest())
+}
+
+func (s *Server) listCheckRuns(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	runs := repo.CheckRuns[r.PathValue("ref")]
+	writeJSON(w, http.StatusOK, github.ListCheckRunsResults{
+		Total:     github.Ptr(len(runs)),
+		CheckRuns: paginate(w, r, runs),
+	})
+}
+
+func (s *Server) createCheckRun(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	var req github.CreateCheckRunOptions
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	s.nextID++
+	run := &github.CheckRun{
+		ID:         github.Ptr(s.nextID),
+		Name:       github.Ptr(req.Name),
+		HeadSHA:    github.Ptr(req.HeadSHA),
+		Status:     req.Status,
+		Conclusion: req.Conclusion,
+		Output:     toCheckRunOutput(req.Output),
+	}
+	repo.CheckRuns[req.HeadSHA] = append(repo.CheckRuns[req.HeadSHA], run)
+
+	writeJSON(w, http.StatusCreated, run)
+}
+
+func (s *Server) updateCheckRun(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	id, ok := s.id(w, r)
+	if !ok {
+		return
+	}
+
+	var req github.UpdateCheckRunOptions
+	if !s.decode(w, r, &req) {
+		return
+	}
+	for _, runs := range repo.CheckRuns {
+		for _, run := range runs {
+			if run.GetID() != id {
+				continue
+			}
+			if req.Status != nil {
+				run.Status = req.Status
+			}
+			if req.Conclusion != nil {
+				run.Conclusion = req.Conclusion
+			}
+			if req.Output != nil {
+				run.Output = toCheckRunOutput(req.Output)
+			}
+			writeJSON(w, http.StatusOK, run)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	id, ok := s.id(w, r)
+	if !ok {
+		return
+	}
+
+	runID, ok := repo.Jobs[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, github.WorkflowJob{ID: github.Ptr(id), RunID: github.Ptr(runID)})
+}
+
+func (s *Server) rerunJob(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	if id, ok := s.id(w, r); ok {
+		repo.RerunJobs = append(repo.RerunJobs, id)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (s *Server) rerunWorkflowRun(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	if id, ok := s.id(w, r); ok {
+		repo.RerunWorkflowRuns = append(repo.RerunWorkflowRuns, id)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (s *Server) getContents(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	ref := r.URL.Query().Get("ref")
+	if len(ref) == 0 {
+		ref = DefaultBranch
+	}
+
+	content, ok := repo.Files[ref][r.PathValue("path")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, github.RepositoryContent{
+		Type:    github.Ptr("file"),
+		Path:    github.Ptr(r.PathValue("path")),
+		Content: github.Ptr(content),
+	})
+}
+
+func (s *Server) getPermission(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	role, ok := repo.Permissions[r.PathValue("login")]
+	if !ok {
+		role = "none"
+	}
+
+	// permission only carries the legacy roles, role_name carries the fine-grained ones
+	permission := role
+	switch role {
+	case "triage":
+		permission = "read"
+	case "maintain":
+		permission = "write"
+	}
+	writeJSON(w, http.StatusOK, github.RepositoryPermissionLevel{
+		Permission: github.Ptr(permission),
+		RoleName:   github.Ptr(role),
+	})
+}
+
+func (s *Server) getMembership(w http.ResponseWriter, r *http.Request) {
+	team := r.PathValue("org") + "/" + r.PathValue("slug")
+	if !slices.Contains(s.Teams[team], r.PathValue("login")) {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, github.Membership{State: github.Ptr("active")})
+}
+
+// getAuthenticatedUser answers as the bot, fail the request with 403 to act as an installation token
+func (s *Server) getAuthenticatedUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, toUser(BotLogin))
+}
+
+func (s *Server) issue(w http.ResponseWriter, r *http.Request, repo *Repo) (*Issue, bool) {
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return nil, false
+	}
+
+	issue, ok := repo.Issues[number]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return nil, false
+	}
+
+	return issue, true
+}
+
+func (s *Server) pullRequest(w http.ResponseWriter, r *http.Request, repo *Repo) (*Issue, bool) {
+	issue, ok := s.issue(w, r, repo)
+	if ok && issue.PullRequest == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return nil, false
+	}
+
+	return issue, ok
+}
+
+func (s *Server) id(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Problems parsing JSON: %v", err))
+		return false
+	}
+
+	return true
+}
+
+func (i *Issue) toGitHub() *github.Issue {
+	issue := &github.Issue{
+		Number: github.Ptr(i.Number),
+		State:  github.Ptr(i.State),
+		User:   &github.User{Login: github.Ptr(i.User)},
+		Labels: toLabels(i.Labels),
+	}
+	if len(i.StateReason) != 0 {
+		issue.StateReason = github.Ptr(i.StateReason)
+	}
+	for _, assignee := range i.Assignees {
+		issue.Assignees = append(issue.Assignees, &github.User{Login: github.Ptr(assignee)})
+	}
+	if i.PullRequest != nil {
+		issue.PullRequestLinks = &github.PullRequestLinks{}
+	}
+
+	return issue
+}
+
+func (i *Issue) toPullRequest() *github.PullRequest {
+	pr := &github.PullRequest{
+		Number: github.Ptr(i.Number),
+		State:  github.Ptr(i.State),
+		User:   &github.User{Login: github.Ptr(i.User)},
+		Labels: toLabels(i.Labels),
+		Head:   &github.PullRequestBranch{SHA: github.Ptr(i.PullRequest.HeadSHA)},
+		Base:   &github.PullRequestBranch{Ref: github.Ptr(i.PullRequest.BaseRef)},
+	}
+	for _, assignee := range i.Assignees {
+		pr.Assignees = append(pr.Assignees, &github.User{Login: github.Ptr(assignee)})
+	}
+	for _, reviewer := range i.PullRequest.RequestedReviewers {
+		pr.RequestedReviewers = append(pr.RequestedReviewers, &github.User{Login: github.Ptr(reviewer)})
+	}
+
+	return pr
+}
+
+func (c *Comment) toGitHub() *github.IssueComment {
+	return &github.IssueComment{
+		ID:   github.Ptr(c.ID),
+		User: toUser(c.User),
+		Body: github.Ptr(c.Body),
+	}
+}
+
+// toUser returns the user of the login, the bot is a bot account while everyone else is a user
+func toUser(login string) *github.User {
+	userType := "User"
+	if login == BotLogin {
+		userType = "Bot"
+	}
+
+	return &github.User{Login: github.Ptr(login), Type: github.Ptr(userType)}
+}
+
+func toLabels(names []string) []*github.Label {
+	labels := make([]*github.Label, 0, len(names))
+	for _, name := range names {
+		labels = append(labels, &github.Label{Name: github.Ptr(name)})
+	}
+
+	return labels
+}
+
+func toCheckRunOutput(output *github.CheckRunOutput) *github.CheckRunOutput {
+	if output == nil {
+		return nil
+	}
+
+	return &github.CheckRunOutput{Title: output.Title, Summary: output.Summary}
+}
+
+// paginate returns the page of the items requested by the page and per_page parameters,
+// and links the next page like GitHub does
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) []T {
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = 30
+	}
+
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	if end < len(items) {
+		next := *r.URL
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+
+	return items[start:end]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
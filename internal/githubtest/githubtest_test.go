@@ -0,0 +1,86 @@
+package githubtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueLabels(t *testing.T) {
+	server := NewServer(t)
+	repo := server.Repo("foo/bar")
+	issue := repo.AddIssue(1, "alice")
+	ghClient := server.Client()
+
+	_, _, err := ghClient.Issues.AddLabelsToIssue(context.Background(), "foo", "bar", 1, []string{"kind/bug", "lgtm"})
+	require.NoError(t, err)
+	_, err = ghClient.Issues.RemoveLabelForIssue(context.Background(), "foo", "bar", 1, "kind/bug")
+	require.NoError(t, err)
+
+	// the label is not on the issue anymore
+	_, err = ghClient.Issues.RemoveLabelForIssue(context.Background(), "foo", "bar", 1, "kind/bug")
+	var errResp *github.ErrorResponse
+	require.ErrorAs(t, err, &errResp)
+	assert.Equal(t, http.StatusNotFound, errResp.Response.StatusCode)
+
+	assert.Equal(t, []string{"lgtm"}, issue.Labels)
+	assert.Equal(t, []string{"kind/bug", "lgtm"}, repo.Labels)
+	assert.Equal(t, []string{
+		"POST /repos/foo/bar/issues/1/labels",
+		"DELETE /repos/foo/bar/issues/1/labels/kind/bug",
+		"DELETE /repos/foo/bar/issues/1/labels/kind/bug",
+	}, server.Mutations())
+}
+
+func TestPagination(t *testing.T) {
+	server := NewServer(t)
+	repo := server.Repo("foo/bar")
+	repo.Labels = []string{"a", "b", "c"}
+
+	labels, resp, err := server.Client().Issues.ListLabels(context.Background(), "foo", "bar", &github.ListOptions{PerPage: 2})
+	require.NoError(t, err)
+	assert.Len(t, labels, 2)
+	assert.Equal(t, 2, resp.NextPage)
+
+	labels, resp, err = server.Client().Issues.ListLabels(context.Background(), "foo", "bar", &github.ListOptions{PerPage: 2, Page: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "c", labels[0].GetName())
+	assert.Zero(t, resp.NextPage)
+}
+
+func TestFail(t *testing.T) {
+	server := NewServer(t)
+	server.Repo("foo/bar").AddIssue(1, "alice")
+	server.Fail("POST /repos/foo/bar/issues/1/comments", http.StatusInternalServerError)
+
+	_, _, err := server.Client().Issues.CreateComment(context.Background(), "foo", "bar", 1, &github.IssueComment{Body: github.Ptr("hi")})
+	var errResp *github.ErrorResponse
+	require.ErrorAs(t, err, &errResp)
+	assert.Equal(t, http.StatusInternalServerError, errResp.Response.StatusCode)
+	assert.Empty(t, server.Repo("foo/bar").Issues[1].Comments)
+}
+
+func TestPermissions(t *testing.T) {
+	server := NewServer(t)
+	server.Repo("foo/bar").Permissions["alice"] = "triage"
+
+	level, _, err := server.Client().Repositories.GetPermissionLevel(context.Background(), "foo", "bar", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "read", level.GetPermission())
+	assert.Equal(t, "triage", level.GetRoleName())
+
+	level, _, err = server.Client().Repositories.GetPermissionLevel(context.Background(), "foo", "bar", "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "none", level.GetPermission())
+
+	assignee, _, err := server.Client().Issues.IsAssignee(context.Background(), "foo", "bar", "alice")
+	require.NoError(t, err)
+	assert.True(t, assignee)
+	assignee, _, err = server.Client().Issues.IsAssignee(context.Background(), "foo", "bar", "bob")
+	require.NoError(t, err)
+	assert.False(t, assignee)
+}
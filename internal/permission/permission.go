@@ -0,0 +1,116 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v72/github"
+
+	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/config"
+)
+
+const activeMembership = "active"
+
+// Checker checks whether a user fulfills the permission requirement of a command.
+// Results of GitHub lookups are cached, so a checker should only live as long as a single event.
+type Checker struct {
+	ghClient *github.Client
+	fullName string
+
+	roles       map[string]string
+	memberships map[string]bool
+}
+
+func NewChecker(ghClient *github.Client, fullName string) *Checker {
+	return &Checker{
+		ghClient:    ghClient,
+		fullName:    fullName,
+		roles:       make(map[string]string),
+		memberships: make(map[string]bool),
+	}
+}
+
+// Allowed reports whether the user fulfills any condition of the requirement
+func (c *Checker) Allowed(ctx context.Context, login string, requirement config.Permission) (bool, error) {
+	if requirement.IsEmpty() {
+		return true, nil
+	}
+
+	for _, user := range requirement.Users {
+		if strings.EqualFold(user, login) {
+			return true, nil
+		}
+	}
+
+	if len(requirement.Role) != 0 {
+		role, err := c.role(ctx, login)
+		if err != nil {
+			return false, err
+		}
+		if roleRank(role) >= roleRank(requirement.Role) {
+			return true, nil
+		}
+	}
+
+	for _, team := range requirement.Teams {
+		member, err := c.isTeamMember(ctx, team, login)
+		if err != nil {
+			return false, err
+		}
+		if member {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// role returns the repository role of the user, "none" for users who are not collaborators
+func (c *Checker) role(ctx context.Context, login string) (string, error) {
+	if role, ok := c.roles[login]; ok {
+		return role, nil
+	}
+
+	owner, repo := actors.GetOwnerRepo(c.fullName)
+	level, _, err := c.ghClient.Repositories.GetPermissionLevel(ctx, owner, repo, login)
+	if err != nil {
+		return "", err
+	}
+
+	// role_name carries the fine-grained roles (triage, maintain) which are folded into permission
+	role := level.GetRoleName()
+	if roleRank(role) < 0 {
+		role = level.GetPermission()
+	}
+	c.roles[login] = role
+
+	return role, nil
+}
+
+func (c *Checker) isTeamMember(ctx context.Context, team, login string) (bool, error) {
+	key := team + "@" + login
+	if member, ok := c.memberships[key]; ok {
+		return member, nil
+	}
+
+	org, slug, _ := strings.Cut(team, "/")
+	membership, _, err := c.ghClient.Teams.GetTeamMembershipBySlug(ctx, org, slug, login)
+	if err != nil {
+		var errResp *github.ErrorResponse
+		if !errors.As(err, &errResp) || errResp.Response == nil || errResp.Response.StatusCode != http.StatusNotFound {
+			return false, err
+		}
+	}
+	member := membership.GetState() == activeMembership
+	c.memberships[key] = member
+
+	return member, nil
+}
+
+func roleRank(role string) int {
+	return slices.Index(config.Roles, role)
+}
@@ -0,0 +1,115 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShyunnY/actbot/internal/config"
+)
+
+func newTestClient(t *testing.T, mux *http.ServeMux) *github.Client {
+	t.Helper()
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ghClient := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	ghClient.BaseURL = baseURL
+
+	return ghClient
+}
+
+func TestAllowed(t *testing.T) {
+	var permissionCalls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/foo/bar/collaborators/{user}/permission", func(w http.ResponseWriter, r *http.Request) {
+		permissionCalls++
+		switch r.PathValue("user") {
+		case "maintainer":
+			_, _ = fmt.Fprint(w, `{"permission":"write","role_name":"maintain"}`)
+		case "triager":
+			_, _ = fmt.Fprint(w, `{"permission":"read","role_name":"triage"}`)
+		default:
+			_, _ = fmt.Fprint(w, `{"permission":"read","role_name":"read"}`)
+		}
+	})
+	mux.HandleFunc("/orgs/foo/teams/reviewers/memberships/{user}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("user") != "reviewer" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"state":"active"}`)
+	})
+
+	checker := NewChecker(newTestClient(t, mux), "foo/bar")
+
+	cases := []struct {
+		caseName    string
+		login       string
+		requirement config.Permission
+		expect      bool
+	}{
+		{
+			caseName: "anyone is allowed without requirement",
+			login:    "stranger",
+			expect:   true,
+		},
+		{
+			caseName:    "user in the allowlist is allowed",
+			login:       "Alice",
+			requirement: config.Permission{Role: "admin", Users: []string{"alice"}},
+			expect:      true,
+		},
+		{
+			caseName:    "role higher than required is allowed",
+			login:       "maintainer",
+			requirement: config.Permission{Role: "write"},
+			expect:      true,
+		},
+		{
+			caseName:    "fine-grained role is taken into account",
+			login:       "triager",
+			requirement: config.Permission{Role: "triage"},
+			expect:      true,
+		},
+		{
+			caseName:    "role lower than required is denied",
+			login:       "stranger",
+			requirement: config.Permission{Role: "triage"},
+			expect:      false,
+		},
+		{
+			caseName:    "team member is allowed",
+			login:       "reviewer",
+			requirement: config.Permission{Role: "write", Teams: []string{"foo/reviewers"}},
+			expect:      true,
+		},
+		{
+			caseName:    "non team member is denied",
+			login:       "stranger",
+			requirement: config.Permission{Teams: []string{"foo/reviewers"}},
+			expect:      false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			allowed, err := checker.Allowed(context.Background(), tc.login, tc.requirement)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, allowed)
+		})
+	}
+
+	// the role of each user is only looked up once
+	assert.Equal(t, 4, permissionCalls)
+}
@@ -0,0 +1,247 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gookit/slog"
+)
+
+const (
+	headerRetryAfter = "Retry-After"
+	headerLimit      = "X-RateLimit-Limit"
+	headerRemaining  = "X-RateLimit-Remaining"
+	headerReset      = "X-RateLimit-Reset"
+
+	// lowQuotaRatio the remaining quota is logged as a warning below this ratio of the limit
+	lowQuotaRatio = 0.1
+)
+
+// ExhaustedError the request could not be completed within the retry budget
+type ExhaustedError struct {
+	Method   string
+	URL      string
+	Attempts int
+
+	// Reason why the last attempt failed
+	Reason string
+
+	// Wait how long GitHub asked to wait before the next attempt, it is zero for transient failures
+	Wait time.Duration
+}
+
+func (e *ExhaustedError) Error() string {
+	msg := fmt.Sprintf("%s %s gave up after %d attempts: %s", e.Method, e.URL, e.Attempts, e.Reason)
+	if e.Wait > 0 {
+		msg += fmt.Sprintf(", retry in %s", e.Wait.Round(time.Second))
+	}
+
+	return msg
+}
+
+// Transport retries the requests rejected by the primary or secondary rate limits of GitHub,
+// honoring the Retry-After and X-RateLimit-Reset headers. Requests which failed because of a transient error
+// are only retried when they are idempotent, with an exponential backoff and jitter.
+type Transport struct {
+	base   http.RoundTripper
+	logger *slog.Logger
+
+	// MaxRetries the maximum number of retries of a request
+	MaxRetries int
+
+	// MaxWait the longest time to wait for a rate limit to be lifted, the request fails immediately beyond it
+	MaxWait time.Duration
+
+	// BaseBackoff the backoff of the first retry of a transient failure, it doubles with each retry
+	BaseBackoff time.Duration
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New returns a transport with the default retry budget, base defaults to http.DefaultTransport
+func New(base http.RoundTripper, logger *slog.Logger) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{
+		base:        base,
+		logger:      logger,
+		MaxRetries:  3,
+		MaxWait:     2 * time.Minute,
+		BaseBackoff: time.Second,
+		now:         time.Now,
+		sleep:       sleep,
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		attemptReq, err := rewind(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err == nil {
+			t.logQuota(resp)
+		}
+
+		wait, reason, retry := t.classify(req, resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+
+		exhausted := &ExhaustedError{Method: req.Method, URL: req.URL.String(), Attempts: attempt, Reason: reason}
+		if wait > t.MaxWait {
+			exhausted.Wait = wait
+		}
+		// the body of a response which is not returned must be drained so that the connection can be reused
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		if attempt > t.MaxRetries || wait > t.MaxWait {
+			t.logger.Errorf("%v", exhausted)
+			return nil, exhausted
+		}
+
+		t.logger.Warnf("%s %s %s, retry in %s (attempt %d/%d)", req.Method, req.URL.Path, reason, wait.Round(time.Millisecond), attempt, t.MaxRetries)
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// classify reports whether the attempt should be retried, how long to wait before it, and why
+func (t *Transport) classify(req *http.Request, resp *http.Response, err error, attempt int) (wait time.Duration, reason string, retry bool) {
+	if err != nil {
+		// the request may have reached GitHub, only retry it when sending it twice is harmless.
+		// A deadline exceeded while the context of the caller is still alive comes from the timeout
+		// of the attempt itself, so it is retried like any other transient failure.
+		if !idempotent(req) || req.Context().Err() != nil || errors.Is(err, context.Canceled) {
+			return 0, "", false
+		}
+		return t.backoff(attempt), err.Error(), true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden:
+		// rejected requests have not been processed, so they are safe to retry whatever the method is
+		if wait, ok := t.retryAfter(resp); ok {
+			return wait, "hit the secondary rate limit", true
+		}
+		if resp.Header.Get(headerRemaining) == "0" {
+			if wait, ok := t.untilReset(resp); ok {
+				return wait, "exhausted the rate limit quota", true
+			}
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return t.backoff(attempt), "hit the rate limit", true
+		}
+		// a genuine permission error
+		return 0, "", false
+	case resp.StatusCode >= http.StatusInternalServerError && idempotent(req):
+		return t.backoff(attempt), resp.Status, true
+	default:
+		return 0, "", false
+	}
+}
+
+// retryAfter parses the Retry-After header, which is either a number of seconds or an HTTP date
+func (t *Transport) retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get(headerRetryAfter)
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(t.now()), 0), true
+	}
+
+	return 0, false
+}
+
+// untilReset returns the time until the quota is reset according to the X-RateLimit-Reset header
+func (t *Transport) untilReset(resp *http.Response) (time.Duration, bool) {
+	reset, err := strconv.ParseInt(resp.Header.Get(headerReset), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	// one more second to tolerate the clock drift
+	return max(time.Unix(reset, 0).Sub(t.now()), 0) + time.Second, true
+}
+
+// backoff returns the exponential backoff of the attempt with a jitter of up to 50%
+func (t *Transport) backoff(attempt int) time.Duration {
+	backoff := t.BaseBackoff << (attempt - 1)
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// logQuota surfaces the remaining quota, it becomes a warning once the quota is running low
+func (t *Transport) logQuota(resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get(headerLimit))
+	if err != nil || limit == 0 {
+		return
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get(headerRemaining))
+	if err != nil {
+		return
+	}
+
+	if float64(remaining) < float64(limit)*lowQuotaRatio {
+		t.logger.Warnf("GitHub API quota is running low: %d/%d remaining", remaining, limit)
+		return
+	}
+	t.logger.Debugf("GitHub API quota: %d/%d remaining", remaining, limit)
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// rewind returns the request to send for the attempt, the body is recreated for every retry
+func rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("%s %s cannot be retried since its body cannot be recreated", req.Method, req.URL)
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	attemptReq := req.Clone(req.Context())
+	attemptReq.Body = body
+
+	return attemptReq, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,304 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gookit/slog"
+	"github.com/gookit/slog/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Unix(1700000000, 0)
+
+func noopLogger() *slog.Logger {
+	return slog.NewWithConfig(func(l *slog.Logger) {
+		l.AddHandler(handler.NewIOWriterHandler(io.Discard, slog.AllLevels))
+	})
+}
+
+// newTransport returns a transport which records the waits instead of sleeping
+func newTransport(waits *[]time.Duration) *Transport {
+	transport := New(http.DefaultTransport, noopLogger())
+	transport.now = func() time.Time { return now }
+	transport.sleep = func(_ context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+
+	return transport
+}
+
+// reply writes the responses in order, the last one is repeated
+type reply struct {
+	status int
+	header map[string]string
+}
+
+func newServer(t *testing.T, replies []reply) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+
+		rep := replies[min(len(bodies), len(replies))-1]
+		for k, v := range rep.header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(rep.status)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &bodies
+}
+
+func TestRoundTrip(t *testing.T) {
+	cases := []struct {
+		caseName string
+		method   string
+		replies  []reply
+
+		expectStatus   int
+		expectAttempts int
+		expectWaits    []time.Duration
+		expectErr      string
+	}{
+		{
+			caseName:       "success",
+			method:         http.MethodGet,
+			replies:        []reply{{status: http.StatusOK}},
+			expectStatus:   http.StatusOK,
+			expectAttempts: 1,
+		},
+		{
+			caseName: "secondary rate limit honors Retry-After",
+			method:   http.MethodPost,
+			replies: []reply{
+				{status: http.StatusForbidden, header: map[string]string{"Retry-After": "30"}},
+				{status: http.StatusCreated},
+			},
+			expectStatus:   http.StatusCreated,
+			expectAttempts: 2,
+			expectWaits:    []time.Duration{30 * time.Second},
+		},
+		{
+			caseName: "primary rate limit waits until the reset",
+			method:   http.MethodGet,
+			replies: []reply{
+				{status: http.StatusForbidden, header: map[string]string{
+					"X-RateLimit-Remaining": "0",
+					"X-RateLimit-Reset":     strconv.FormatInt(now.Add(10*time.Second).Unix(), 10),
+				}},
+				{status: http.StatusOK},
+			},
+			expectStatus:   http.StatusOK,
+			expectAttempts: 2,
+			expectWaits:    []time.Duration{11 * time.Second},
+		},
+		{
+			caseName:       "permission error is not retried",
+			method:         http.MethodGet,
+			replies:        []reply{{status: http.StatusForbidden}},
+			expectStatus:   http.StatusForbidden,
+			expectAttempts: 1,
+		},
+		{
+			caseName:       "server error of a mutation is not retried",
+			method:         http.MethodPost,
+			replies:        []reply{{status: http.StatusBadGateway}},
+			expectStatus:   http.StatusBadGateway,
+			expectAttempts: 1,
+		},
+		{
+			caseName: "server error of an idempotent call is retried",
+			method:   http.MethodPut,
+			replies: []reply{
+				{status: http.StatusBadGateway},
+				{status: http.StatusServiceUnavailable},
+				{status: http.StatusOK},
+			},
+			expectStatus:   http.StatusOK,
+			expectAttempts: 3,
+		},
+		{
+			caseName:       "retries are exhausted",
+			method:         http.MethodGet,
+			replies:        []reply{{status: http.StatusBadGateway}},
+			expectAttempts: 4,
+			expectErr:      "gave up after 4 attempts: 502 Bad Gateway",
+		},
+		{
+			caseName: "reset is too far away",
+			method:   http.MethodGet,
+			replies: []reply{
+				{status: http.StatusForbidden, header: map[string]string{
+					"X-RateLimit-Remaining": "0",
+					"X-RateLimit-Reset":     strconv.FormatInt(now.Add(time.Hour).Unix(), 10),
+				}},
+			},
+			expectAttempts: 1,
+			expectErr:      "gave up after 1 attempts: exhausted the rate limit quota, retry in 1h0m1s",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			server, bodies := newServer(t, tc.replies)
+
+			var waits []time.Duration
+			transport := newTransport(&waits)
+
+			req, err := http.NewRequest(tc.method, server.URL+"/repos/foo/bar", strings.NewReader("payload"))
+			require.NoError(t, err)
+
+			resp, err := transport.RoundTrip(req)
+			if len(tc.expectErr) != 0 {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectErr)
+				var exhausted *ExhaustedError
+				assert.True(t, errors.As(err, &exhausted))
+			} else {
+				require.NoError(t, err)
+				defer resp.Body.Close()
+				assert.Equal(t, tc.expectStatus, resp.StatusCode)
+			}
+
+			require.Len(t, *bodies, tc.expectAttempts)
+			// the body is sent again with every retry
+			for _, body := range *bodies {
+				assert.Equal(t, "payload", body)
+			}
+			if tc.expectWaits != nil {
+				assert.Equal(t, tc.expectWaits, waits)
+			}
+		})
+	}
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	transport := newTransport(new([]time.Duration))
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", now.Add(time.Minute).UTC().Format(http.TimeFormat))
+	wait, ok := transport.retryAfter(resp)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, wait)
+
+	resp.Header.Set("Retry-After", "soon")
+	_, ok = transport.retryAfter(resp)
+	assert.False(t, ok)
+}
+
+func TestBackoff(t *testing.T) {
+	transport := newTransport(new([]time.Duration))
+
+	for attempt := 1; attempt <= 4; attempt++ {
+		expect := time.Second << (attempt - 1)
+		for range 20 {
+			backoff := transport.backoff(attempt)
+			assert.GreaterOrEqual(t, backoff, expect/2)
+			assert.LessOrEqual(t, backoff, expect)
+		}
+	}
+}
+
+func TestRoundTripCanceled(t *testing.T) {
+	server, bodies := newServer(t, []reply{{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "5"}}})
+
+	transport := New(http.DefaultTransport, noopLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleep(ctx, d)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	_, err = transport.RoundTrip(req)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, *bodies, 1)
+}
+
+func TestRoundTripAttemptTimeout(t *testing.T) {
+	cases := []struct {
+		caseName       string
+		method         string
+		expectAttempts int
+	}{
+		{
+			caseName:       "timed out idempotent call is retried",
+			method:         http.MethodGet,
+			expectAttempts: 2,
+		},
+		{
+			caseName:       "timed out mutation is not retried",
+			method:         http.MethodPost,
+			expectAttempts: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			var attempts int
+			// the first attempt times out, the following ones succeed
+			transport := newTransport(new([]time.Duration))
+			transport.base = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				attempts++
+				if attempts == 1 {
+					return nil, context.DeadlineExceeded
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+			})
+
+			req, err := http.NewRequest(tc.method, "https://api.github.com/repos/foo/bar", nil)
+			require.NoError(t, err)
+
+			resp, err := transport.RoundTrip(req)
+			if tc.expectAttempts == 1 {
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+			} else {
+				require.NoError(t, err)
+				defer resp.Body.Close()
+			}
+			assert.Equal(t, tc.expectAttempts, attempts)
+		})
+	}
+}
+
+// a deadline of the caller is final, unlike the timeout of an attempt
+func TestRoundTripCallerDeadline(t *testing.T) {
+	transport := newTransport(new([]time.Duration))
+	var attempts int
+	transport.base = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/foo/bar", nil)
+	require.NoError(t, err)
+
+	_, err = transport.RoundTrip(req)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, attempts)
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -1,29 +1,112 @@
 package internal
 
 import (
+	"encoding/json"
+
 	"github.com/google/go-github/v72/github"
 	"github.com/gookit/slog"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/ShyunnY/actbot/internal/actors"
+	"github.com/ShyunnY/actbot/internal/actors/approve"
 	"github.com/ShyunnY/actbot/internal/actors/assign"
 	"github.com/ShyunnY/actbot/internal/actors/cc"
+	"github.com/ShyunnY/actbot/internal/actors/hold"
 	"github.com/ShyunnY/actbot/internal/actors/label"
+	"github.com/ShyunnY/actbot/internal/actors/lgtm"
+	"github.com/ShyunnY/actbot/internal/actors/lifecycle"
 	"github.com/ShyunnY/actbot/internal/actors/retest"
+	"github.com/ShyunnY/actbot/internal/config"
 )
 
 type GitHubEventType string
 
-type RegisterFn = func(ghClient *github.Client, logger *slog.Logger) actors.Actor
+type RegisterFn = func(ghClient *github.Client, logger *slog.Logger, cfg *config.Config) actors.Actor
 
 const (
-	IssueComment GitHubEventType = "issue_comment"
+	IssueComment             GitHubEventType = "issue_comment"
+	PullRequest              GitHubEventType = "pull_request"
+	PullRequestTarget        GitHubEventType = "pull_request_target"
+	Issues                   GitHubEventType = "issues"
+	PullRequestReview        GitHubEventType = "pull_request_review"
+	PullRequestReviewComment GitHubEventType = "pull_request_review_comment"
 )
 
+// createdAction the action of comment events which are dispatched, edited and deleted comments are ignored
+const createdAction = "created"
+
+// eventDecoders decodes the payload of each supported GitHub event into its go-github type
+var eventDecoders = map[GitHubEventType]func(payload []byte) (any, error){
+	IssueComment:             decodeEvent[github.IssueCommentEvent],
+	PullRequest:              decodeEvent[github.PullRequestEvent],
+	PullRequestTarget:        decodeEvent[github.PullRequestEvent],
+	Issues:                   decodeEvent[github.IssuesEvent],
+	PullRequestReview:        decodeEvent[github.PullRequestReviewEvent],
+	PullRequestReviewComment: decodeEvent[github.PullRequestReviewCommentEvent],
+}
+
 var actorMap = map[GitHubEventType][]RegisterFn{
 	IssueComment: {
 		assign.NewAssignActor,
 		retest.NewRetestActor,
 		label.NewLabelActor,
 		cc.NewCCActor,
+		lgtm.NewLGTMActor,
+		approve.NewApproveActor,
+		hold.NewHoldActor,
+		lifecycle.NewLifecycleActor,
+	},
+	PullRequest: {
+		lgtm.NewLGTMActor,
+		approve.NewApproveActor,
+		hold.NewHoldActor,
+	},
+	PullRequestTarget: {
+		lgtm.NewLGTMActor,
+		approve.NewApproveActor,
+		hold.NewHoldActor,
+	},
+	Issues: {
+		assign.NewAssignActor,
+	},
+	PullRequestReview: {
+		lgtm.NewLGTMActor,
+	},
+	PullRequestReviewComment: {
+		lgtm.NewLGTMActor,
 	},
 }
+
+func decodeEvent[T any](payload []byte) (any, error) {
+	var evt T
+	if err := json.Unmarshal(payload, &evt); err != nil {
+		return nil, err
+	}
+
+	return evt, nil
+}
+
+// registeredCommands returns the names of the commands handled by all registered actors,
+// the commands may depend on the configuration, e.g. the label shortcuts
+func registeredCommands(cfg *config.Config) []string {
+	names := sets.New[string]()
+	for _, fns := range actorMap {
+		for _, fn := range fns {
+			names.Insert(fn(nil, logger, cfg).Commands()...)
+		}
+	}
+
+	return sets.List(names)
+}
+
+// registeredActors returns the names of all registered actors
+func registeredActors() []string {
+	names := sets.New[string]()
+	for _, fns := range actorMap {
+		for _, fn := range fns {
+			names.Insert(fn(nil, logger, config.Default()).Name())
+		}
+	}
+
+	return sets.List(names)
+}
@@ -0,0 +1,356 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v72/github"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// maxPayloadBytes GitHub caps webhook payloads at 25 MB
+	maxPayloadBytes = 25 << 20
+
+	pingEvent = "ping"
+
+	shutdownTimeout = 30 * time.Second
+)
+
+// ServerOptions the inputs of the webhook server
+type ServerOptions struct {
+	// Addr the address to listen on, e.g. :8080
+	Addr string
+
+	// Path the path receiving webhook deliveries
+	Path string
+
+	// Secret the webhook secret used to verify the X-Hub-Signature-256 header
+	Secret string
+
+	// Credentials without an installation id, a GitHub App acts as the installation each delivery comes from
+	Credentials
+	Endpoint
+
+	// ConfigPath path of the configuration file, it is fetched from the default branch of each repository
+	ConfigPath string
+
+	// Workers the number of deliveries processed concurrently
+	Workers int
+
+	// QueueSize the number of deliveries waiting to be processed, deliveries are rejected once the queue is full
+	QueueSize int
+
+	// DeliveryCacheSize the number of recent delivery ids remembered to drop redeliveries
+	DeliveryCacheSize int
+
+	// DryRun logs every request which would mutate the repository instead of sending it
+	DryRun bool
+
+	// Timeout the deadline of processing each delivery, deliveries are not bounded when it is zero
+	Timeout time.Duration
+
+	// CallTimeout the deadline of each request sent to GitHub, requests are not bounded when it is zero
+	CallTimeout time.Duration
+}
+
+// delivery a webhook delivery waiting to be processed
+type delivery struct {
+	id      string
+	event   string
+	payload []byte
+}
+
+type server struct {
+	opts       ServerOptions
+	ghClient   *github.Client
+	deliveries *deliveryCache
+
+	// installationClients the clients of each installation of the GitHub App, keyed by installation id
+	mu                  sync.Mutex
+	installationClients map[int64]*github.Client
+
+	queue chan delivery
+	wg    sync.WaitGroup
+
+	// process handles a single delivery, it is replaceable for testing only
+	process func(d delivery) error
+}
+
+// Serve runs actbot as a webhook server until ctx is cancelled,
+// deliveries which have been accepted are processed before it returns.
+func Serve(ctx context.Context, opts ServerOptions) error {
+	switch {
+	case len(opts.Secret) == 0:
+		return errors.New("empty webhook secret")
+	case opts.Workers <= 0:
+		return fmt.Errorf("invalid number of workers %d", opts.Workers)
+	case opts.QueueSize < 0:
+		return fmt.Errorf("invalid queue size %d", opts.QueueSize)
+	}
+
+	ghClient, err := newGitHubClient(opts.Credentials, opts.Endpoint, "", opts.DryRun, opts.CallTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to init GitHub client by err: %w", err)
+	}
+
+	s := newServer(opts, ghClient)
+	s.start()
+
+	mux := http.NewServeMux()
+	mux.Handle(opts.Path, s)
+	httpServer := &http.Server{
+		Addr:              opts.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Infof("webhook server is listening on %s%s", opts.Addr, opts.Path)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		logger.Infof("shutting down the webhook server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = httpServer.Shutdown(shutdownCtx)
+	}
+
+	// no handler is running anymore, so the queue can be drained safely
+	s.stop()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+func newServer(opts ServerOptions, ghClient *github.Client) *server {
+	s := &server{
+		opts:       opts,
+		ghClient:   ghClient,
+		deliveries: newDeliveryCache(opts.DeliveryCacheSize),
+		queue:      make(chan delivery, opts.QueueSize),
+
+		installationClients: make(map[int64]*github.Client),
+	}
+	s.process = s.dispatch
+
+	return s
+}
+
+// start starts the workers
+func (s *server) start() {
+	for range s.opts.Workers {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for d := range s.queue {
+				s.handle(d)
+			}
+		}()
+	}
+}
+
+// stop waits for the queued deliveries to be processed, no delivery must be enqueued afterward
+func (s *server) stop() {
+	close(s.queue)
+	s.wg.Wait()
+}
+
+func (s *server) handle(d delivery) {
+	// a broken delivery must never take the whole server down
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("delivery %s of '%s' event panicked: %v", d.id, d.event, r)
+		}
+	}()
+
+	if err := s.process(d); err != nil {
+		logger.Errorf("failed to process delivery %s of '%s' event by err: %v", d.id, d.event, err)
+		return
+	}
+	logger.Infof("delivery %s of '%s' event has been processed", d.id, d.event)
+}
+
+// dispatch loads the configuration of the repository the delivery belongs to and runs the actors.
+// The delivery is bounded by its own deadline instead of the lifetime of the server,
+// so that the deliveries which have been accepted are completed during the shutdown.
+func (s *server) dispatch(d delivery) error {
+	ctx := context.Background()
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+
+	repo, err := payloadRepo(d.payload)
+	if err != nil {
+		return fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	ghClient, err := s.client(d.payload)
+	if err != nil {
+		return fmt.Errorf("failed to init GitHub client by err: %w", err)
+	}
+
+	cfg, err := loadConfig(ctx, ghClient, repo, "", s.opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration by err: %w", err)
+	}
+
+	return dispatchPayload(ctx, d.event, d.payload, ghClient, cfg)
+}
+
+// client returns the client acting as the installation the delivery comes from when a GitHub App is used
+// without a fixed installation, otherwise the shared client.
+func (s *server) client(payload []byte) (*github.Client, error) {
+	if s.opts.AppID == 0 || s.opts.AppInstallationID != 0 {
+		return s.ghClient, nil
+	}
+
+	var event struct {
+		Installation struct {
+			ID int64 `json:"id"`
+		} `json:"installation"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	if event.Installation.ID == 0 {
+		return s.ghClient, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ghClient, ok := s.installationClients[event.Installation.ID]; ok {
+		return ghClient, nil
+	}
+	creds := s.opts.Credentials
+	creds.AppInstallationID = event.Installation.ID
+	ghClient, err := newGitHubClient(creds, s.opts.Endpoint, "", s.opts.DryRun, s.opts.CallTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s.installationClients[event.Installation.ID] = ghClient
+
+	return ghClient, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, "invalid content type", http.StatusBadRequest)
+		return
+	}
+	payload, err := github.ValidatePayloadFromBody(
+		contentType,
+		http.MaxBytesReader(w, r.Body, maxPayloadBytes),
+		r.Header.Get(github.SHA256SignatureHeader),
+		[]byte(s.opts.Secret),
+	)
+	if err != nil {
+		logger.Warnf("reject webhook delivery by err: %v", err)
+		http.Error(w, "invalid payload", http.StatusUnauthorized)
+		return
+	}
+
+	d := delivery{
+		id:      github.DeliveryID(r),
+		event:   github.WebHookType(r),
+		payload: payload,
+	}
+	switch _, ok := eventDecoders[GitHubEventType(d.event)]; {
+	case d.event == pingEvent:
+		_, _ = fmt.Fprint(w, "pong")
+		return
+	case !ok:
+		// nothing to do, but GitHub must not consider the delivery as failed
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case len(d.id) == 0:
+		http.Error(w, "missing delivery id", http.StatusBadRequest)
+		return
+	}
+
+	if !s.deliveries.add(d.id) {
+		logger.Infof("delivery %s has already been received, skip it", d.id)
+		_, _ = fmt.Fprint(w, "duplicate delivery")
+		return
+	}
+
+	select {
+	case s.queue <- d:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		// let GitHub redeliver it later
+		s.deliveries.remove(d.id)
+		logger.Warnf("queue is full, reject delivery %s of '%s' event", d.id, d.event)
+		http.Error(w, "too many deliveries", http.StatusServiceUnavailable)
+	}
+}
+
+// deliveryCache remembers the most recent delivery ids, the oldest id is evicted once the capacity is reached
+type deliveryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ids      sets.Set[string]
+	order    []string
+}
+
+func newDeliveryCache(capacity int) *deliveryCache {
+	return &deliveryCache{
+		capacity: max(capacity, 1),
+		ids:      sets.New[string](),
+	}
+}
+
+// add reports whether the id has not been seen before
+func (c *deliveryCache) add(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ids.Has(id) {
+		return false
+	}
+	if len(c.order) == c.capacity {
+		c.ids.Delete(c.order[0])
+		c.order = c.order[1:]
+	}
+	c.ids.Insert(id)
+	c.order = append(c.order, id)
+
+	return true
+}
+
+func (c *deliveryCache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.ids.Has(id) {
+		return
+	}
+	c.ids.Delete(id)
+	for i, orderID := range c.order {
+		if orderID == id {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
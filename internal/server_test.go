@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v72/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "s3cr3t"
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDelivery(id, event, payload, signature string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(github.EventTypeHeader, event)
+	req.Header.Set(github.DeliveryIDHeader, id)
+	if len(signature) != 0 {
+		req.Header.Set(github.SHA256SignatureHeader, signature)
+	}
+
+	return req
+}
+
+func TestServeHTTP(t *testing.T) {
+	payload := `{"action":"created","repository":{"full_name":"foo/bar"}}`
+
+	cases := []struct {
+		caseName   string
+		req        *http.Request
+		expectCode int
+		queued     int
+	}{
+		{
+			caseName:   "valid delivery is queued",
+			req:        newDelivery("1", "issue_comment", payload, sign(payload)),
+			expectCode: http.StatusAccepted,
+			queued:     1,
+		},
+		{
+			caseName:   "missing signature is rejected",
+			req:        newDelivery("1", "issue_comment", payload, ""),
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			caseName:   "signature of another payload is rejected",
+			req:        newDelivery("1", "issue_comment", payload, sign("{}")),
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			caseName:   "ping is answered",
+			req:        newDelivery("1", "ping", payload, sign(payload)),
+			expectCode: http.StatusOK,
+		},
+		{
+			caseName:   "unsupported event is ignored",
+			req:        newDelivery("1", "push", payload, sign(payload)),
+			expectCode: http.StatusNoContent,
+		},
+		{
+			caseName:   "delivery without id is rejected",
+			req:        newDelivery("", "issue_comment", payload, sign(payload)),
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			caseName:   "only post is allowed",
+			req:        httptest.NewRequest(http.MethodGet, "/webhook", nil),
+			expectCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.caseName, func(t *testing.T) {
+			s := newServer(ServerOptions{Secret: testSecret, Workers: 1, QueueSize: 1, DeliveryCacheSize: 10}, nil)
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, tc.req)
+
+			assert.Equal(t, tc.expectCode, rec.Code)
+			assert.Len(t, s.queue, tc.queued)
+		})
+	}
+}
+
+func TestServeHTTPDeliveries(t *testing.T) {
+	payload := `{"action":"created","repository":{"full_name":"foo/bar"}}`
+	s := newServer(ServerOptions{Secret: testSecret, Workers: 2, QueueSize: 2, DeliveryCacheSize: 10}, nil)
+
+	deliver := func(id string) int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, newDelivery(id, "issue_comment", payload, sign(payload)))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, deliver("1"))
+	// a redelivery is acknowledged without being processed twice
+	assert.Equal(t, http.StatusOK, deliver("1"))
+	assert.Equal(t, http.StatusAccepted, deliver("2"))
+	// the queue is full, the delivery must be redelivered later
+	assert.Equal(t, http.StatusServiceUnavailable, deliver("3"))
+
+	var (
+		mu        sync.Mutex
+		processed []string
+	)
+	s.process = func(d delivery) error {
+		mu.Lock()
+		processed = append(processed, d.id)
+		mu.Unlock()
+
+		if d.id == "1" {
+			panic("boom")
+		}
+		return nil
+	}
+	s.start()
+
+	// a panicking delivery does not take the workers down
+	require.Eventually(t, func() bool {
+		return deliver("3") == http.StatusAccepted
+	}, time.Second, 10*time.Millisecond)
+	s.stop()
+
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, processed)
+}
+
+func TestDeliveryCache(t *testing.T) {
+	c := newDeliveryCache(2)
+	require.True(t, c.add("1"))
+	require.True(t, c.add("2"))
+	assert.False(t, c.add("1"))
+
+	// the oldest id is evicted
+	require.True(t, c.add("3"))
+	assert.True(t, c.add("1"))
+	assert.False(t, c.add("3"))
+
+	c.remove("3")
+	assert.True(t, c.add("3"))
+}
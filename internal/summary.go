@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ShyunnY/actbot/internal/commands"
+	"github.com/ShyunnY/actbot/internal/config"
+)
+
+type outcome int
+
+const (
+	handled outcome = iota
+	denied
+	failed
+)
+
+// actorResult the outcome of an actor which captured a command
+type actorResult struct {
+	actor   string
+	outcome outcome
+
+	// requirement the description of the permission the user does not fulfill, only set when denied
+	requirement string
+	err         error
+}
+
+// commandResult the outcomes of all actors which captured the command,
+// it is empty when no actor is interested in the command
+type commandResult struct {
+	command commands.Command
+	results []actorResult
+}
+
+// summary collects the result of each command of a comment in textual order
+type summary struct {
+	commands []commandResult
+}
+
+func (s *summary) add(cmd commands.Command, results []actorResult) {
+	s.commands = append(s.commands, commandResult{command: cmd, results: results})
+}
+
+// render renders the summary as a markdown table below the configured header
+func (s *summary) render(cfg *config.Config, user string) (string, error) {
+	header, err := config.RenderReply(cfg.Replies.CommandsSummary, config.ReplyData{User: user})
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(header)
+	sb.WriteString("\n\n| Command | Result |\n|---------|--------|\n")
+	for _, cr := range s.commands {
+		fmt.Fprintf(&sb, "| `%s` | %s |\n", escapeCell(cr.command.Raw), cr.describe())
+	}
+
+	return sb.String(), nil
+}
+
+func (cr commandResult) describe() string {
+	if len(cr.results) == 0 {
+		return ":grey_question: ignored, no actor handles this command"
+	}
+
+	descriptions := make([]string, 0, len(cr.results))
+	for _, r := range cr.results {
+		var desc string
+		switch r.outcome {
+		case handled:
+			desc = fmt.Sprintf(":white_check_mark: handled by %s", r.actor)
+		case denied:
+			desc = fmt.Sprintf(":no_entry: denied, %s requires %s", r.actor, r.requirement)
+		case failed:
+			desc = fmt.Sprintf(":x: %s failed: %v", r.actor, r.err)
+		}
+		descriptions = append(descriptions, escapeCell(desc))
+	}
+
+	return strings.Join(descriptions, "<br>")
+}
+
+// renderFailures renders the actors which failed as a markdown list below the configured header,
+// ok is false when no actor failed
+func renderFailures(cfg *config.Config, user string, results []actorResult) (body string, ok bool, err error) {
+	var sb strings.Builder
+	for _, r := range results {
+		if r.outcome == failed {
+			fmt.Fprintf(&sb, "- %s: %s\n", r.actor, escapeCell(r.err.Error()))
+		}
+	}
+	if sb.Len() == 0 {
+		return "", false, nil
+	}
+
+	header, err := config.RenderReply(cfg.Replies.ActorsFailed, config.ReplyData{User: user})
+	if err != nil {
+		return "", false, err
+	}
+
+	return header + "\n\n" + sb.String(), true, nil
+}
+
+// escapeCell keeps the text inside a single markdown table cell
+func escapeCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "`", "'").Replace(text)
+}
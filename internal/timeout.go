@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultTimeout the deadline of a whole run
+	defaultTimeout = 5 * time.Minute
+
+	// defaultCallTimeout the deadline of a single request sent to GitHub
+	defaultCallTimeout = 30 * time.Second
+)
+
+// timeoutTransport bounds the time spent on each request, reading the response body included.
+// Each attempt of the retrying transport wrapping it gets a fresh deadline, an attempt which timed out
+// before the response arrived is retried when the request is idempotent. A timed-out mutation is final
+// since it may have reached GitHub, and so is a timeout while reading the body.
+type timeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the deadline must outlive RoundTrip since the body is read by the caller afterward
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// cancelBody releases the deadline of the request once the response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
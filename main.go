@@ -1,14 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/ShyunnY/actbot/internal"
 )
 
 func main() {
-	if err := internal.Setup(); err != nil {
+	// the runner sends SIGTERM when the job is cancelled, the requests in flight are cancelled with the context
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// inside GitHub Actions the action is started without arguments
+	if len(os.Args) > 1 {
+		if err := internal.Execute(ctx, os.Args[1:]); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			stop()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := internal.Setup(ctx); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
+		stop()
+		os.Exit(1)
 	}
 }